@@ -124,6 +124,13 @@ RETURNS ulid
 AS '$libdir/ulid', 'ulid_generate'
 LANGUAGE C VOLATILE;
 
+-- Experimental: only the first entropy_bytes of entropy are random, the rest
+-- are zero. Only 10 (the default above) is spec-canonical.
+CREATE OR REPLACE FUNCTION ulid_random(entropy_bytes INTEGER)
+RETURNS ulid
+AS '$libdir/ulid', 'ulid_generate_with_entropy_bytes'
+LANGUAGE C VOLATILE STRICT;
+
 CREATE OR REPLACE FUNCTION ulid()
 RETURNS ulid
 AS '$libdir/ulid', 'ulid_generate_monotonic'
@@ -158,6 +165,147 @@ RETURNS ulid
 AS '$libdir/ulid', 'ulid_generate_with_timestamp'
 LANGUAGE C IMMUTABLE STRICT;
 
+-- Replace the 80-bit entropy of a ULID, keeping its timestamp
+CREATE OR REPLACE FUNCTION ulid_set_entropy(ulid_str TEXT, entropy_hex TEXT)
+RETURNS TEXT
+AS '$libdir/ulid', 'ulid_set_entropy'
+LANGUAGE C IMMUTABLE STRICT;
+
+-- Stable shard index in [0, num_shards) derived from the entropy bytes
+CREATE OR REPLACE FUNCTION ulid_shard(ulid_str TEXT, num_shards INTEGER)
+RETURNS INTEGER
+AS '$libdir/ulid', 'ulid_shard'
+LANGUAGE C IMMUTABLE STRICT;
+
+-- Leading n bytes (1..16) of the binary ULID, for compact index prefixes
+CREATE OR REPLACE FUNCTION ulid_first_n_bytes(ulid_str TEXT, n INTEGER)
+RETURNS BYTEA
+AS '$libdir/ulid', 'ulid_first_n_bytes'
+LANGUAGE C IMMUTABLE STRICT;
+
+-- Node tag stored in the leading width (1-10) entropy bytes
+CREATE OR REPLACE FUNCTION ulid_machine_id(ulid_str TEXT, width INTEGER)
+RETURNS BYTEA
+AS '$libdir/ulid', 'ulid_machine_id'
+LANGUAGE C IMMUTABLE STRICT;
+
+-- Group key: timestamp floored to granularity_ms with zero entropy
+CREATE OR REPLACE FUNCTION ulid_time_only(ulid_str TEXT, granularity_ms BIGINT DEFAULT 1000)
+RETURNS TEXT
+AS '$libdir/ulid', 'ulid_time_only'
+LANGUAGE C IMMUTABLE STRICT;
+
+-- Bits [from_bit, to_bit) of the 80-bit entropy as an integer, bit 0 being
+-- the most significant; 64-bit ranges wrap into negative bigints
+CREATE OR REPLACE FUNCTION ulid_entropy_bits(ulid_str TEXT, from_bit INTEGER, to_bit INTEGER)
+RETURNS BIGINT
+AS '$libdir/ulid', 'ulid_entropy_bits'
+LANGUAGE C IMMUTABLE STRICT;
+
+-- Total order over ULID text: '' sorts first, valid ULIDs by binary value,
+-- NULL last
+CREATE OR REPLACE FUNCTION ulid_text_cmp(a TEXT, b TEXT)
+RETURNS INTEGER
+AS '$libdir/ulid', 'ulid_text_cmp'
+LANGUAGE C IMMUTABLE;
+
+-- Sort key for ULID text: ORDER BY (or an expression index on) this key
+-- gives ulid_text_cmp order
+CREATE OR REPLACE FUNCTION ulid_text_sort_key(ulid_str TEXT)
+RETURNS BYTEA
+AS '$libdir/ulid', 'ulid_text_sort_key'
+LANGUAGE C IMMUTABLE STRICT;
+
+-- Remove a required prefix from a typed ID and return the canonical ULID
+CREATE OR REPLACE FUNCTION ulid_strip_prefix(value TEXT, prefix TEXT)
+RETURNS TEXT
+AS '$libdir/ulid', 'ulid_strip_prefix'
+LANGUAGE C IMMUTABLE STRICT;
+
+-- Return the prefix of a typed ID whose last 26 characters are a ULID
+CREATE OR REPLACE FUNCTION ulid_extract_prefix(value TEXT)
+RETURNS TEXT
+AS '$libdir/ulid', 'ulid_extract_prefix'
+LANGUAGE C IMMUTABLE STRICT;
+
+-- True when the text is a spec-valid ULID, exactly when ulid_parse_error is
+-- NULL (never raises)
+CREATE OR REPLACE FUNCTION ulid_is_valid(ulid_str TEXT)
+RETURNS BOOLEAN
+AS '$libdir/ulid', 'ulid_is_valid'
+LANGUAGE C IMMUTABLE STRICT;
+
+-- Best-effort repair: strip whitespace/hyphens, uppercase, map I/L->1 and O->0
+CREATE OR REPLACE FUNCTION ulid_validate_and_fix(ulid_str TEXT)
+RETURNS TEXT
+AS '$libdir/ulid', 'ulid_validate_and_fix'
+LANGUAGE C IMMUTABLE STRICT;
+
+-- True when the text encodes a timestamp above the 48-bit maximum
+CREATE OR REPLACE FUNCTION ulid_overflow_bit(ulid_str TEXT)
+RETURNS BOOLEAN
+AS '$libdir/ulid', 'ulid_overflow_bit'
+LANGUAGE C IMMUTABLE STRICT;
+
+-- Why the text is not a spec-valid ULID (wrong length, invalid character,
+-- timestamp overflow); NULL when it is valid
+CREATE OR REPLACE FUNCTION ulid_parse_error(ulid_str TEXT)
+RETURNS TEXT
+AS '$libdir/ulid', 'ulid_parse_error'
+LANGUAGE C IMMUTABLE STRICT;
+
+-- Like ulid_parse, but only the canonical uppercase form is accepted
+CREATE OR REPLACE FUNCTION ulid_parse_strict(ulid_str TEXT)
+RETURNS ulid
+AS '$libdir/ulid', 'ulid_parse_strict'
+LANGUAGE C IMMUTABLE STRICT;
+
+-- True when b may directly follow a in a monotonic sequence (b > a)
+CREATE OR REPLACE FUNCTION ulid_is_monotonic_pair(a TEXT, b TEXT)
+RETURNS BOOLEAN
+AS '$libdir/ulid', 'ulid_is_monotonic_pair'
+LANGUAGE C IMMUTABLE STRICT;
+
+-- Count of ULIDs strictly between a and b (either order), saturating at
+-- the bigint maximum when the 128-bit gap does not fit
+CREATE OR REPLACE FUNCTION ulid_successor_gap(a TEXT, b TEXT)
+RETURNS BIGINT
+AS '$libdir/ulid', 'ulid_successor_gap'
+LANGUAGE C IMMUTABLE STRICT;
+
+-- Generate ULID at a timestamptz (errors outside the 48-bit ms range)
+CREATE OR REPLACE FUNCTION ulid_generate_at(ts TIMESTAMPTZ)
+RETURNS TEXT
+AS '$libdir/ulid', 'ulid_generate_at'
+LANGUAGE C VOLATILE STRICT;
+
+-- Generate a ULID strictly greater than prev: uses max(now, prev's timestamp)
+-- and increments prev's entropy when the clock has not moved past it
+CREATE OR REPLACE FUNCTION ulid_make_ordered(prev TEXT)
+RETURNS TEXT
+AS '$libdir/ulid', 'ulid_make_ordered'
+LANGUAGE C VOLATILE STRICT;
+
+-- Inclusive text bounds covering every ULID created in [from_ts, to_ts]
+-- at millisecond precision, for BETWEEN scans over text ULID keys
+CREATE OR REPLACE FUNCTION ulid_prefix_scan_bounds(from_ts TIMESTAMPTZ, to_ts TIMESTAMPTZ,
+                                                   OUT lo TEXT, OUT hi TEXT)
+RETURNS RECORD
+AS '$libdir/ulid', 'ulid_prefix_scan_bounds'
+LANGUAGE C IMMUTABLE STRICT;
+
+-- Stream count monotonic ULIDs as rows instead of building an array
+CREATE OR REPLACE FUNCTION ulid_batch_srf(count INTEGER)
+RETURNS SETOF TEXT
+AS '$libdir/ulid', 'ulid_batch_srf'
+LANGUAGE C VOLATILE STRICT;
+
+-- Stream count ULIDs all at timestamp_ms, strictly increasing within that ms
+CREATE OR REPLACE FUNCTION ulid_generate_n_at(timestamp_ms BIGINT, count INTEGER)
+RETURNS SETOF TEXT
+AS '$libdir/ulid', 'ulid_generate_n_at'
+LANGUAGE C VOLATILE STRICT;
+
 -- ============================================================================
 -- ULID CONVENIENCE FUNCTIONS (SQL-based)
 -- ============================================================================
@@ -190,19 +338,374 @@ AS $$
     SELECT to_timestamp(ulid_timestamp(ulid_in(ulid_str::cstring)) / 1000.0);
 $$ LANGUAGE sql IMMUTABLE STRICT;
 
+-- ULID time as an interval since 1970-01-01 UTC (hours, not days or months)
+CREATE OR REPLACE FUNCTION ulid_to_interval_since_epoch(ulid_str TEXT)
+RETURNS INTERVAL
+AS $$
+    SELECT ulid_timestamp(ulid_in(ulid_str::cstring)) * interval '1 millisecond';
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+-- Render the ULID timestamp as ISO 8601 in the given IANA zone (UTC when NULL)
+CREATE OR REPLACE FUNCTION ulid_timestamp_iso(ulid_str TEXT, tz TEXT)
+RETURNS TEXT
+AS $$
+    WITH t AS (
+        SELECT to_timestamp(ulid_timestamp(ulid_in(ulid_str::cstring)) / 1000.0) AS ts,
+               COALESCE(tz, 'UTC') AS zone
+    ), l AS (
+        SELECT timezone(zone, ts) AS local_ts,
+               timezone(zone, ts) - timezone('UTC', ts) AS utc_offset
+        FROM t
+    )
+    SELECT to_char(local_ts, 'YYYY-MM-DD"T"HH24:MI:SS.MS') ||
+           CASE
+               WHEN utc_offset = interval '0' THEN 'Z'
+               WHEN utc_offset < interval '0' THEN '-' || to_char(-utc_offset, 'HH24:MI')
+               ELSE '+' || to_char(utc_offset, 'HH24:MI')
+           END
+    FROM l;
+$$ LANGUAGE sql STABLE;
+
+-- True when two ULIDs carry identical entropy bytes (a sign of a broken RNG)
+CREATE OR REPLACE FUNCTION ulid_entropy_overlap(a TEXT, b TEXT)
+RETURNS BOOLEAN
+AS $$
+    SELECT substring(ulid_send(ulid_in(a::cstring)) FROM 7) =
+           substring(ulid_send(ulid_in(b::cstring)) FROM 7);
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+-- True when lo <= x <= hi in ulid_text_cmp order
+CREATE OR REPLACE FUNCTION ulid_between(lo TEXT, hi TEXT, x TEXT)
+RETURNS BOOLEAN
+AS $$
+    SELECT ulid_text_cmp(lo, x) <= 0 AND ulid_text_cmp(x, hi) <= 0;
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+-- True when comparing a and b as text (under the call's collation) gives
+-- the same sign as comparing them as ULIDs; false flags a collation hazard
+CREATE OR REPLACE FUNCTION ulid_compare_text_vs_binary(a TEXT, b TEXT)
+RETURNS BOOLEAN
+AS $$
+    SELECT (CASE WHEN a < b THEN -1 WHEN a > b THEN 1 ELSE 0 END) = ulid_text_cmp(a, b);
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+-- Smallest ULID: Unix epoch with zero entropy
+CREATE OR REPLACE FUNCTION ulid_epoch()
+RETURNS TEXT
+AS $$
+    SELECT '00000000000000000000000000'::text;
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Largest ULID the text form carries: 26 characters hold the 128 bits shifted
+-- left by two, so the top two bits are always clear (bytes 3fff...ff)
+CREATE OR REPLACE FUNCTION ulid_max_value()
+RETURNS TEXT
+AS $$
+    SELECT '7ZZZZZZZZZZZZZZZZZZZZZZZZW'::text;
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Nil ULID (all zero bytes), analogous to the nil UUID
+CREATE OR REPLACE FUNCTION ulid_nil()
+RETURNS TEXT
+AS $$
+    SELECT '00000000000000000000000000'::text;
+$$ LANGUAGE sql IMMUTABLE;
+
+-- True when the ULID is the nil ULID
+CREATE OR REPLACE FUNCTION ulid_is_nil(ulid_str TEXT)
+RETURNS BOOLEAN
+AS $$
+    SELECT ulid_in(ulid_str::cstring) = ulid_in(ulid_nil()::cstring);
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+-- Decompose a ULID into a jsonb document
+CREATE OR REPLACE FUNCTION ulid_to_jsonb(ulid_str TEXT)
+RETURNS JSONB
+AS $$
+    SELECT jsonb_build_object(
+        'ulid', ulid_out(u)::text,
+        'timestamp_ms', ulid_timestamp(u),
+        'timestamp_iso', ulid_timestamp_iso(ulid_str, NULL),
+        'entropy_hex', encode(substring(ulid_send(u) FROM 7), 'hex')
+    )
+    FROM (SELECT ulid_in(ulid_str::cstring) AS u) s;
+$$ LANGUAGE sql STABLE STRICT;
+
+-- True when the ULID was created no earlier than now() - window; IDs stamped
+-- slightly ahead of the database clock still count as recent
+CREATE OR REPLACE FUNCTION ulid_within_last(ulid_str TEXT, window_size INTERVAL)
+RETURNS BOOLEAN
+AS $$
+    SELECT to_timestamp(ulid_timestamp(ulid_in(ulid_str::cstring)) / 1000.0) >= now() - window_size;
+$$ LANGUAGE sql STABLE STRICT;
+
+-- Coarse age label relative to now() in the session time zone:
+-- 'today', 'this_week', 'this_month' or 'older'
+CREATE OR REPLACE FUNCTION ulid_age_bucket(ulid_str TEXT)
+RETURNS TEXT
+AS $$
+    SELECT CASE
+               WHEN ts >= date_trunc('day', now()) THEN 'today'
+               WHEN ts >= date_trunc('week', now()) THEN 'this_week'
+               WHEN ts >= date_trunc('month', now()) THEN 'this_month'
+               ELSE 'older'
+           END
+    FROM (SELECT to_timestamp(ulid_timestamp(ulid_in(ulid_str::cstring)) / 1000.0) AS ts) s;
+$$ LANGUAGE sql STABLE STRICT;
+
+-- UTC time-series key for a named granularity, e.g. '2024-06-01T13' for 'hour'
+CREATE OR REPLACE FUNCTION ulid_to_time_bucket_key(ulid_str TEXT, bucket TEXT)
+RETURNS TEXT
+AS $$
+DECLARE
+    fmt TEXT;
+BEGIN
+    fmt := CASE lower(bucket)
+               WHEN 'minute' THEN 'YYYY-MM-DD"T"HH24:MI'
+               WHEN 'hour' THEN 'YYYY-MM-DD"T"HH24'
+               WHEN 'day' THEN 'YYYY-MM-DD'
+           END;
+    IF fmt IS NULL THEN
+        RAISE EXCEPTION 'invalid time bucket: "%"', bucket
+            USING ERRCODE = 'invalid_parameter_value',
+                  HINT = 'Valid buckets are minute, hour and day.';
+    END IF;
+    RETURN to_char(timezone('UTC', to_timestamp(ulid_timestamp_text(ulid_str) / 1000.0)), fmt);
+END;
+$$ LANGUAGE plpgsql STABLE STRICT;
+
 -- Batch generation functions
+-- ulid_batch: monotonic ULIDs, strictly increasing in array order
 CREATE OR REPLACE FUNCTION ulid_batch(count INTEGER)
 RETURNS ulid[]
 AS $$
-    SELECT array_agg(ulid()) FROM generate_series(1, count);
+    SELECT array_agg(ulid() ORDER BY i) FROM generate_series(1, count) AS i;
 $$ LANGUAGE sql VOLATILE;
 
+-- ulid_batch(count, seed): reproducible batch for snapshot tests. Every ID sits
+-- at the Unix epoch; entropy is 6 seed-derived bytes followed by a 4-byte
+-- counter, so the array is strictly increasing and identical for equal seeds
+CREATE OR REPLACE FUNCTION ulid_batch(count INTEGER, seed BIGINT)
+RETURNS ulid[]
+AS $$
+    SELECT array_agg(
+               ulid_in(ulid_set_entropy(ulid_epoch(),
+                                        left(md5(seed::text), 12) || lpad(to_hex(i), 8, '0'))::cstring)
+               ORDER BY i)
+    FROM generate_series(1, count) AS i;
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+-- ulid_random_batch: independent random ULIDs, no ordering guarantee
 CREATE OR REPLACE FUNCTION ulid_random_batch(count INTEGER)
 RETURNS ulid[]
 AS $$
     SELECT array_agg(ulid_random()) FROM generate_series(1, count);
 $$ LANGUAGE sql VOLATILE;
 
+-- ulid_values: ready-to-paste VALUES body of n monotonic ULIDs, ('...'),('...')
+CREATE OR REPLACE FUNCTION ulid_values(n INTEGER)
+RETURNS TEXT
+AS $$
+BEGIN
+    IF n < 1 OR n > 10000 THEN
+        RAISE EXCEPTION 'n must be between 1 and 10000, got %', n
+            USING ERRCODE = 'invalid_parameter_value';
+    END IF;
+    RETURN (SELECT string_agg('(''' || ulid_out(ulid())::text || ''')', ',' ORDER BY i)
+            FROM generate_series(1, n) AS i);
+END;
+$$ LANGUAGE plpgsql VOLATILE STRICT;
+
+-- Count ULIDs per bucket_ms-wide time bucket; NULL and invalid entries are skipped
+CREATE OR REPLACE FUNCTION ulid_timestamp_histogram(ulids TEXT[], bucket_ms BIGINT)
+RETURNS TABLE (bucket_start TIMESTAMPTZ, count BIGINT)
+AS $$
+BEGIN
+    IF bucket_ms <= 0 THEN
+        RAISE EXCEPTION 'bucket_ms must be greater than zero, got %', bucket_ms
+            USING ERRCODE = 'invalid_parameter_value';
+    END IF;
+
+    RETURN QUERY
+    SELECT to_timestamp((s.ts - s.ts % bucket_ms) / 1000.0), COUNT(*)
+    FROM (
+        SELECT ulid_timestamp_text(v) AS ts
+        FROM unnest(ulids) AS v
+        WHERE ulid_is_valid(v)
+    ) s
+    GROUP BY 1
+    ORDER BY 1;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE STRICT;
+
+-- Element-wise ULID timestamps; NULL elements stay NULL, invalid elements
+-- raise unless strict_mode is off, in which case they become NULL too
+CREATE OR REPLACE FUNCTION ulid_to_timestamptz_array(ulids TEXT[], strict_mode BOOLEAN DEFAULT true)
+RETURNS TIMESTAMPTZ[]
+AS $$
+    SELECT COALESCE(array_agg(
+        CASE
+            WHEN v IS NULL THEN NULL
+            WHEN NOT strict_mode AND NOT ulid_is_valid(v) THEN NULL
+            ELSE to_timestamp(ulid_timestamp(ulid_in(v::cstring)) / 1000.0)
+        END
+        ORDER BY ord), '{}')
+    FROM unnest(ulids) WITH ORDINALITY AS t(v, ord);
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+-- Drop later duplicates (by ulid_text_sort_key, so case-insensitive and ''
+-- allowed), keeping the first occurrence of each ULID in its original
+-- position and spelling
+CREATE OR REPLACE FUNCTION ulid_dedupe_array(ulids TEXT[])
+RETURNS TEXT[]
+AS $$
+    SELECT COALESCE(array_agg(v ORDER BY ord), '{}')
+    FROM (
+        SELECT DISTINCT ON (ulid_text_sort_key(v)) v, ord
+        FROM unnest(ulids) WITH ORDINALITY AS t(v, ord)
+        ORDER BY ulid_text_sort_key(v), ord
+    ) s;
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+-- Stable sort in ulid_text_cmp order ('' first, then by 16-byte value),
+-- independent of collation; NULLs go last in both directions
+CREATE OR REPLACE FUNCTION ulid_sort_array(ulids TEXT[], descending BOOLEAN DEFAULT false)
+RETURNS TEXT[]
+AS $$
+    SELECT COALESCE(array_agg(v ORDER BY
+                        CASE WHEN NOT descending THEN k END ASC NULLS LAST,
+                        CASE WHEN descending THEN k END DESC NULLS LAST,
+                        ord), '{}')
+    FROM (
+        SELECT v, ord, ulid_text_sort_key(v) AS k
+        FROM unnest(ulids) WITH ORDINALITY AS t(v, ord)
+    ) s;
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+-- Candidate whose timestamp is closest to target_ts (ms); ties go to the
+-- smaller ULID. NULL elements are ignored, NULL when none remain
+CREATE OR REPLACE FUNCTION ulid_nearest(target_ts BIGINT, candidates TEXT[])
+RETURNS TEXT
+AS $$
+    SELECT v
+    FROM unnest(candidates) AS v
+    WHERE v IS NOT NULL
+    ORDER BY abs(ulid_timestamp(ulid_in(v::cstring)) - target_ts), ulid_send(ulid_in(v::cstring))
+    LIMIT 1;
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+-- Pearson chi-square statistic of entropy byte frequencies against a uniform
+-- distribution (255 degrees of freedom, so healthy RNGs score near 255);
+-- NULL and invalid entries are skipped, NULL when nothing remains
+CREATE OR REPLACE FUNCTION ulid_entropy_score(ulids TEXT[])
+RETURNS NUMERIC
+AS $$
+    WITH bytes AS (
+        SELECT get_byte(ulid_send(ulid_in(v::cstring)), i) AS b
+        FROM unnest(ulids) AS v, generate_series(6, 15) AS i
+        WHERE ulid_is_valid(v)
+    ), expected AS (
+        SELECT count(*) / 256.0 AS e FROM bytes
+    ), observed AS (
+        SELECT g.b, count(bytes.b) AS o
+        FROM generate_series(0, 255) AS g(b) LEFT JOIN bytes ON bytes.b = g.b
+        GROUP BY g.b
+    )
+    SELECT sum((o - e) * (o - e) / e)
+    FROM observed, expected
+    WHERE e > 0;
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+-- Spread of (ULID timestamp - now()) in ms across the array; positive skew
+-- means the generating host's clock ran ahead. NULL and invalid entries are skipped
+CREATE OR REPLACE FUNCTION ulid_clock_skew_report(ulids TEXT[],
+                                                  OUT min_skew_ms BIGINT,
+                                                  OUT max_skew_ms BIGINT,
+                                                  OUT avg_skew_ms NUMERIC)
+RETURNS RECORD
+AS $$
+    SELECT min(skew), max(skew), avg(skew)
+    FROM (
+        SELECT ulid_timestamp(ulid_in(v::cstring))
+               - floor(extract(epoch FROM now()) * 1000)::bigint AS skew
+        FROM unnest(ulids) AS v
+        WHERE ulid_is_valid(v)
+    ) s;
+$$ LANGUAGE sql STABLE STRICT;
+
+-- Return the input when it is a valid non-nil ULID; generate a fresh one for NULL, '' or nil
+CREATE OR REPLACE FUNCTION ulid_coalesce_nil(ulid_str TEXT)
+RETURNS TEXT
+AS $$
+BEGIN
+    IF ulid_str IS NULL OR ulid_str = '' THEN
+        RETURN ulid()::text;
+    END IF;
+    IF ulid_is_nil(ulid_str) THEN
+        RETURN ulid()::text;
+    END IF;
+    RETURN ulid_str;
+END;
+$$ LANGUAGE plpgsql VOLATILE;
+
+-- ============================================================================
+-- ULID TRIGGER FUNCTIONS
+-- ============================================================================
+
+-- Assign a monotonic ULID to the column named by TG_ARGV[0] when it is NULL.
+-- Usage:
+--   CREATE TRIGGER orders_ulid BEFORE INSERT ON orders
+--   FOR EACH ROW EXECUTE FUNCTION ulid_assign_trigger('id');
+CREATE OR REPLACE FUNCTION ulid_assign_trigger()
+RETURNS trigger
+AS $$
+DECLARE
+    target_column TEXT;
+BEGIN
+    IF TG_NARGS < 1 THEN
+        RAISE EXCEPTION 'ulid_assign_trigger() requires the target column name as its first argument';
+    END IF;
+
+    target_column := TG_ARGV[0];
+    IF NOT (to_jsonb(NEW) ? target_column) THEN
+        RAISE EXCEPTION 'column "%" does not exist in table "%"', target_column, TG_TABLE_NAME
+            USING ERRCODE = 'undefined_column';
+    END IF;
+
+    IF to_jsonb(NEW) ->> target_column IS NULL THEN
+        NEW := jsonb_populate_record(NEW, jsonb_build_object(target_column, ulid()::text));
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql VOLATILE;
+
+-- ============================================================================
+-- ULID AGGREGATES
+-- ============================================================================
+
+-- ulid_text_cmp-order min/max over ULID text, independent of the column collation
+CREATE OR REPLACE FUNCTION ulid_text_smaller(a TEXT, b TEXT)
+RETURNS TEXT
+AS '$libdir/ulid', 'ulid_text_smaller'
+LANGUAGE C IMMUTABLE STRICT;
+
+CREATE OR REPLACE FUNCTION ulid_text_larger(a TEXT, b TEXT)
+RETURNS TEXT
+AS '$libdir/ulid', 'ulid_text_larger'
+LANGUAGE C IMMUTABLE STRICT;
+
+CREATE AGGREGATE ulid_min_agg(TEXT) (
+    SFUNC = ulid_text_smaller,
+    STYPE = TEXT,
+    COMBINEFUNC = ulid_text_smaller
+);
+
+CREATE AGGREGATE ulid_max_agg(TEXT) (
+    SFUNC = ulid_text_larger,
+    STYPE = TEXT,
+    COMBINEFUNC = ulid_text_larger
+);
+
 -- ============================================================================
 -- ULID CASTING FUNCTIONS
 -- ============================================================================
@@ -255,13 +758,83 @@ AS $$
     SELECT ulid_send(ulid_val);
 $$ LANGUAGE sql IMMUTABLE STRICT;
 
--- Convert bytea to ULID (for casting) - using proper binary conversion
+-- Convert bytea to ULID (for casting) - requires exactly 16 bytes
 CREATE OR REPLACE FUNCTION bytea_to_ulid_cast(bytea_val bytea)
 RETURNS ulid
+AS '$libdir/ulid', 'bytea_to_ulid'
+LANGUAGE C IMMUTABLE STRICT;
+
+-- Convert ULID text to its 16-byte binary form
+CREATE OR REPLACE FUNCTION ulid_to_bytea(ulid_str TEXT)
+RETURNS BYTEA
 AS $$
-    SELECT ulid_in(encode(bytea_val, 'base64')::cstring);
+    SELECT ulid_send(ulid_in(ulid_str::cstring));
 $$ LANGUAGE sql IMMUTABLE STRICT;
 
+-- Convert 16-byte binary form to ULID text
+CREATE OR REPLACE FUNCTION ulid_from_bytea(bytea_val BYTEA)
+RETURNS TEXT
+AS $$
+    SELECT ulid_out(bytea_to_ulid_cast(bytea_val))::text;
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+-- 22-character unpadded base64url form of the 16 bytes, for JSON and URLs
+CREATE OR REPLACE FUNCTION ulid_compact(ulid_str TEXT)
+RETURNS TEXT
+AS $$
+    SELECT rtrim(translate(encode(ulid_to_bytea(ulid_str), 'base64'), '+/', '-_'), '=');
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+-- Inverse of ulid_compact; only canonical 22-character base64url is accepted
+CREATE OR REPLACE FUNCTION ulid_expand(compact TEXT)
+RETURNS TEXT
+AS $$
+BEGIN
+    IF compact !~ '^[A-Za-z0-9_-]{21}[AQgw]$' THEN
+        RAISE EXCEPTION 'invalid compact ulid: "%"', compact
+            USING ERRCODE = 'invalid_text_representation',
+                  DETAIL = 'Expected 22 base64url characters encoding exactly 16 bytes.';
+    END IF;
+    RETURN ulid_from_bytea(decode(translate(compact, '-_', '+/') || '==', 'base64'));
+END;
+$$ LANGUAGE plpgsql IMMUTABLE STRICT;
+
+-- ULID text as its 16 bytes, each an integer 0-255
+CREATE OR REPLACE FUNCTION ulid_to_array(ulid_str TEXT)
+RETURNS INT[]
+AS $$
+    SELECT array_agg(get_byte(ulid_to_bytea(ulid_str), i) ORDER BY i)
+    FROM generate_series(0, 15) AS i;
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+-- ULID as its unsigned 128-bit big-endian integer value
+CREATE OR REPLACE FUNCTION ulid_to_numeric(ulid_str TEXT)
+RETURNS NUMERIC
+AS $$
+    SELECT sum(get_byte(b, i) * power(256::numeric, 15 - i))::numeric(39, 0)
+    FROM (SELECT ulid_to_bytea(ulid_str) AS b) s, generate_series(0, 15) AS i;
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+-- Build ULID text from exactly 16 integers in 0-255
+CREATE OR REPLACE FUNCTION ulid_from_array(bytes INT[])
+RETURNS TEXT
+AS $$
+BEGIN
+    IF array_ndims(bytes) IS DISTINCT FROM 1 OR cardinality(bytes) <> 16 THEN
+        RAISE EXCEPTION 'ulid array must have exactly 16 elements, got %', cardinality(bytes)
+            USING ERRCODE = 'invalid_parameter_value';
+    END IF;
+    IF EXISTS (SELECT 1 FROM unnest(bytes) AS b WHERE b IS NULL OR b < 0 OR b > 255) THEN
+        RAISE EXCEPTION 'ulid array elements must be between 0 and 255'
+            USING ERRCODE = 'invalid_parameter_value';
+    END IF;
+    RETURN ulid_from_bytea(decode(
+        (SELECT string_agg(lpad(to_hex(b), 2, '0'), '' ORDER BY ord)
+         FROM unnest(bytes) WITH ORDINALITY AS t(b, ord)),
+        'hex'));
+END;
+$$ LANGUAGE plpgsql IMMUTABLE STRICT;
+
 -- ============================================================================
 -- ULID CASTS
 -- ============================================================================
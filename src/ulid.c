@@ -8,6 +8,7 @@
 
 #include "postgres.h"
 #include "fmgr.h"
+#include "funcapi.h"
 #include "utils/builtins.h"
 #include "utils/array.h"
 #include "utils/lsyscache.h"
@@ -20,6 +21,9 @@
 #include "utils/pg_locale.h"
 #include "utils/elog.h"
 #include "libpq/pqformat.h"
+#include "mb/pg_wchar.h"
+#include "utils/guc.h"
+#include "access/xact.h"
 
 #include <ctype.h>
 #include <time.h>
@@ -35,6 +39,8 @@
 
 PG_MODULE_MAGIC;
 
+void _PG_init(void);
+
 typedef struct ULID
 {
     unsigned char data[16];
@@ -42,6 +48,9 @@ typedef struct ULID
 
 static const char base32_alphabet[] = "0123456789ABCDEFGHJKMNPQRSTVWXYZ";
 #define ULID_TEXT_LEN 26
+#define ULID_TIMESTAMP_LEN 6
+#define ULID_ENTROPY_LEN 10
+#define ULID_MAX_TIMESTAMP_MS ((int64_t)0xFFFFFFFFFFFFLL)
 
 /* Portable 128-bit accumulator support */
 #if defined(__SIZEOF_INT128__) || defined(__GNUC__) || defined(__clang__)
@@ -368,6 +377,28 @@ static void generate_ulid_bytes(ULID* out)
     fill_random_bytes(out->data + 6, 10);
 }
 
+/* pg_ulid.deterministic: ulid() entropy is a per-transaction sequence */
+static bool ulid_deterministic = false;
+static uint64_t deterministic_seq = 0;
+
+/* every xact callback event marks the end of the current transaction */
+static void reset_deterministic_seq(XactEvent event, void* arg)
+{
+    (void)event;
+    (void)arg;
+    deterministic_seq = 0;
+}
+
+static void fill_deterministic_entropy(ULID* out)
+{
+    int i;
+
+    deterministic_seq++;
+    memset(out->data + ULID_TIMESTAMP_LEN, 0, ULID_ENTROPY_LEN);
+    for (i = 0; i < 8; i++)
+        out->data[8 + i] = (unsigned char)((deterministic_seq >> (56 - i * 8)) & 0xFF);
+}
+
 /* monotonic generator */
 static void generate_ulid_monotonic_bytes(ULID* out)
 {
@@ -389,6 +420,12 @@ static void generate_ulid_monotonic_bytes(ULID* out)
     out->data[4] = (last_time_ms >> 8) & 0xFF;
     out->data[5] = last_time_ms & 0xFF;
 
+    if (ulid_deterministic)
+    {
+        fill_deterministic_entropy(out);
+        return;
+    }
+
     out->data[6] = (counter >> 24) & 0xFF;
     out->data[7] = (counter >> 16) & 0xFF;
     out->data[8] = (counter >> 8) & 0xFF;
@@ -397,7 +434,7 @@ static void generate_ulid_monotonic_bytes(ULID* out)
     fill_random_bytes(out->data + 10, 6);
 }
 
-static void generate_ulid_with_ts_bytes(ULID* out, int64_t timestamp_ms)
+static void write_ulid_timestamp(ULID* out, int64_t timestamp_ms)
 {
     out->data[0] = (timestamp_ms >> 40) & 0xFF;
     out->data[1] = (timestamp_ms >> 32) & 0xFF;
@@ -405,6 +442,11 @@ static void generate_ulid_with_ts_bytes(ULID* out, int64_t timestamp_ms)
     out->data[3] = (timestamp_ms >> 16) & 0xFF;
     out->data[4] = (timestamp_ms >> 8) & 0xFF;
     out->data[5] = timestamp_ms & 0xFF;
+}
+
+static void generate_ulid_with_ts_bytes(ULID* out, int64_t timestamp_ms)
+{
+    write_ulid_timestamp(out, timestamp_ms);
     fill_random_bytes(out->data + 6, 10);
 }
 
@@ -420,6 +462,130 @@ static int64_t extract_timestamp_ms_from_ulid_bytes(const ULID* in)
     return (int64_t)ts;
 }
 
+/* decode text -> bytes, raising the type's input error on failure */
+static void decode_ulid_text_or_error(const char* input, ULID* out)
+{
+    if (!decode_ulid_text_to_bytes(input, out))
+    {
+        ereport(ERROR, (errcode(ERRCODE_INVALID_TEXT_REPRESENTATION),
+                        errmsg("invalid input syntax for type ulid: \"%s\"", input)));
+    }
+}
+
+/*
+ * Total order over ULID text: the empty string sorts before every valid
+ * ULID, valid ULIDs compare by binary value, anything else is an error.
+ */
+static int compare_ulid_text(const char* a, const char* b)
+{
+    ULID ua;
+    ULID ub;
+    int cmp;
+
+    if (a[0] == '\0' || b[0] == '\0')
+        return (a[0] != '\0') - (b[0] != '\0');
+
+    decode_ulid_text_or_error(a, &ua);
+    decode_ulid_text_or_error(b, &ub);
+    cmp = memcmp(ua.data, ub.data, 16);
+    return (cmp > 0) - (cmp < 0);
+}
+
+/* hex digit value */
+static int hex_val(char c)
+{
+    if (c >= '0' && c <= '9')
+        return c - '0';
+    if (c >= 'a' && c <= 'f')
+        return c - 'a' + 10;
+    if (c >= 'A' && c <= 'F')
+        return c - 'A' + 10;
+    return -1;
+}
+
+/* decode exactly 2*n hex digits into n bytes */
+static bool decode_hex_bytes(const char* hex, unsigned char* out, size_t n)
+{
+    size_t i;
+
+    if (strlen(hex) != n * 2)
+        return false;
+    for (i = 0; i < n; i++)
+    {
+        int hi = hex_val(hex[i * 2]);
+        int lo = hex_val(hex[i * 2 + 1]);
+        if (hi < 0 || lo < 0)
+            return false;
+        out[i] = (unsigned char)((hi << 4) | lo);
+    }
+    return true;
+}
+
+/* timestamptz -> unix ms, rejecting values outside the 48-bit ULID range */
+static int64_t timestamptz_to_ulid_ms(TimestampTz ts)
+{
+    int64_t ms;
+
+    if (TIMESTAMP_NOT_FINITE(ts))
+    {
+        ereport(ERROR, (errcode(ERRCODE_DATETIME_VALUE_OUT_OF_RANGE),
+                        errmsg("timestamp out of range for ulid")));
+    }
+
+    /* floor to ms, then shift from the 2000-01-01 Postgres epoch */
+    ms = (int64_t)(ts / 1000);
+    if (ts % 1000 < 0)
+        ms--;
+    ms += (int64_t)(POSTGRES_EPOCH_JDATE - UNIX_EPOCH_JDATE) * SECS_PER_DAY * 1000;
+
+    if (ms < 0 || ms > ULID_MAX_TIMESTAMP_MS)
+    {
+        ereport(ERROR, (errcode(ERRCODE_DATETIME_VALUE_OUT_OF_RANGE),
+                        errmsg("timestamp out of range for ulid"),
+                        errdetail("ULID timestamps cover 1970-01-01 UTC through 2^48-1 milliseconds after it.")));
+    }
+    return ms;
+}
+
+/* add one to the 80-bit entropy; false when it was already all ones */
+static bool increment_entropy(ULID* u)
+{
+    int i;
+
+    for (i = 15; i >= ULID_TIMESTAMP_LEN; i--)
+    {
+        if (++u->data[i] != 0)
+            return true;
+    }
+    return false;
+}
+
+/* big-endian 64-bit word of a ULID, word 0 or 1 */
+static uint64_t ulid_word(const ULID* u, int word)
+{
+    uint64_t v = 0;
+    int i;
+
+    for (i = 0; i < 8; i++)
+        v = (v << 8) | u->data[word * 8 + i];
+    return v;
+}
+
+void _PG_init(void)
+{
+    DefineCustomBoolVariable("pg_ulid.deterministic",
+                             "Makes ulid() use a deterministic per-transaction entropy sequence.",
+                             "Intended for reproducible tests; IDs are not unique across transactions.",
+                             &ulid_deterministic,
+                             false,
+                             PGC_USERSET,
+                             0,
+                             NULL,
+                             NULL,
+                             NULL);
+    RegisterXactCallback(reset_deterministic_seq, NULL);
+}
+
 /* Postgres functions */
 
 PG_FUNCTION_INFO_V1(ulid_in);
@@ -584,3 +750,560 @@ Datum ulid_hash(PG_FUNCTION_ARGS)
         h = h * 31 + u->data[i];
     PG_RETURN_INT32((int32_t)h);
 }
+
+PG_FUNCTION_INFO_V1(ulid_set_entropy);
+Datum ulid_set_entropy(PG_FUNCTION_ARGS)
+{
+    char* input = text_to_cstring(PG_GETARG_TEXT_PP(0));
+    char* entropy_hex = text_to_cstring(PG_GETARG_TEXT_PP(1));
+    char* result = (char*)palloc(ULID_TEXT_LEN + 1);
+    ULID u;
+
+    decode_ulid_text_or_error(input, &u);
+    if (!decode_hex_bytes(entropy_hex, u.data + ULID_TIMESTAMP_LEN, ULID_ENTROPY_LEN))
+    {
+        ereport(ERROR, (errcode(ERRCODE_INVALID_PARAMETER_VALUE),
+                        errmsg("invalid ulid entropy: \"%s\"", entropy_hex),
+                        errdetail("Entropy must be exactly %d hexadecimal characters.",
+                                  ULID_ENTROPY_LEN * 2)));
+    }
+    encode_bytes_to_ulid_text(&u, result);
+    PG_RETURN_TEXT_P(cstring_to_text(result));
+}
+
+PG_FUNCTION_INFO_V1(ulid_generate_at);
+Datum ulid_generate_at(PG_FUNCTION_ARGS)
+{
+    TimestampTz ts = PG_GETARG_TIMESTAMPTZ(0);
+    char* result = (char*)palloc(ULID_TEXT_LEN + 1);
+    ULID u;
+
+    generate_ulid_with_ts_bytes(&u, timestamptz_to_ulid_ms(ts));
+    encode_bytes_to_ulid_text(&u, result);
+    PG_RETURN_TEXT_P(cstring_to_text(result));
+}
+
+PG_FUNCTION_INFO_V1(bytea_to_ulid);
+Datum bytea_to_ulid(PG_FUNCTION_ARGS)
+{
+    bytea* input = PG_GETARG_BYTEA_PP(0);
+    ULID* r;
+
+    if (VARSIZE_ANY_EXHDR(input) != sizeof(r->data))
+    {
+        ereport(ERROR, (errcode(ERRCODE_INVALID_BINARY_REPRESENTATION),
+                        errmsg("invalid ulid binary length: %d", (int)VARSIZE_ANY_EXHDR(input)),
+                        errdetail("A ULID is exactly 16 bytes.")));
+    }
+    r = (ULID*)palloc(sizeof(ULID));
+    memcpy(r->data, VARDATA_ANY(input), sizeof(r->data));
+    PG_RETURN_POINTER(r);
+}
+
+PG_FUNCTION_INFO_V1(ulid_batch_srf);
+Datum ulid_batch_srf(PG_FUNCTION_ARGS)
+{
+    FuncCallContext* funcctx;
+
+    if (SRF_IS_FIRSTCALL())
+    {
+        int32 count = PG_GETARG_INT32(0);
+        funcctx = SRF_FIRSTCALL_INIT();
+        funcctx->max_calls = count > 0 ? (uint64)count : 0;
+    }
+
+    funcctx = SRF_PERCALL_SETUP();
+    if (funcctx->call_cntr < funcctx->max_calls)
+    {
+        ULID u;
+        char buf[ULID_TEXT_LEN + 1];
+        generate_ulid_monotonic_bytes(&u);
+        encode_bytes_to_ulid_text(&u, buf);
+        SRF_RETURN_NEXT(funcctx, PointerGetDatum(cstring_to_text(buf)));
+    }
+    SRF_RETURN_DONE(funcctx);
+}
+
+PG_FUNCTION_INFO_V1(ulid_shard);
+Datum ulid_shard(PG_FUNCTION_ARGS)
+{
+    char* input = text_to_cstring(PG_GETARG_TEXT_PP(0));
+    int32 num_shards = PG_GETARG_INT32(1);
+    uint32_t h = 2166136261u;
+    ULID u;
+    int i;
+
+    if (num_shards <= 0)
+    {
+        ereport(ERROR, (errcode(ERRCODE_INVALID_PARAMETER_VALUE),
+                        errmsg("num_shards must be greater than zero")));
+    }
+    decode_ulid_text_or_error(input, &u);
+
+    /* FNV-1a over the entropy only, so shard choice has no temporal skew */
+    for (i = ULID_TIMESTAMP_LEN; i < 16; i++)
+    {
+        h ^= u.data[i];
+        h *= 16777619u;
+    }
+    PG_RETURN_INT32((int32)(h % (uint32_t)num_shards));
+}
+
+/* shared body of ulid_text_smaller / ulid_text_larger */
+static Datum ulid_text_pick(FunctionCallInfo fcinfo, bool smaller)
+{
+    text* a = PG_GETARG_TEXT_PP(0);
+    text* b = PG_GETARG_TEXT_PP(1);
+    int cmp = compare_ulid_text(text_to_cstring(a), text_to_cstring(b));
+
+    if (smaller ? cmp <= 0 : cmp >= 0)
+        PG_RETURN_TEXT_P(a);
+    PG_RETURN_TEXT_P(b);
+}
+
+PG_FUNCTION_INFO_V1(ulid_text_smaller);
+Datum ulid_text_smaller(PG_FUNCTION_ARGS)
+{
+    return ulid_text_pick(fcinfo, true);
+}
+
+PG_FUNCTION_INFO_V1(ulid_text_larger);
+Datum ulid_text_larger(PG_FUNCTION_ARGS)
+{
+    return ulid_text_pick(fcinfo, false);
+}
+
+PG_FUNCTION_INFO_V1(ulid_first_n_bytes);
+Datum ulid_first_n_bytes(PG_FUNCTION_ARGS)
+{
+    char* input = text_to_cstring(PG_GETARG_TEXT_PP(0));
+    int32 n = PG_GETARG_INT32(1);
+    bytea* result;
+    ULID u;
+
+    if (n < 1 || n > (int32)sizeof(u.data))
+    {
+        ereport(ERROR, (errcode(ERRCODE_INVALID_PARAMETER_VALUE),
+                        errmsg("byte count must be between 1 and 16, got %d", n)));
+    }
+    decode_ulid_text_or_error(input, &u);
+
+    result = (bytea*)palloc(VARHDRSZ + n);
+    SET_VARSIZE(result, VARHDRSZ + n);
+    memcpy(VARDATA(result), u.data, n);
+    PG_RETURN_BYTEA_P(result);
+}
+
+PG_FUNCTION_INFO_V1(ulid_text_cmp);
+Datum ulid_text_cmp(PG_FUNCTION_ARGS)
+{
+    char* a;
+    char* b;
+
+    /* NULL sorts after every value, matching ORDER BY ... ASC NULLS LAST */
+    if (PG_ARGISNULL(0) || PG_ARGISNULL(1))
+        PG_RETURN_INT32(PG_ARGISNULL(0) - PG_ARGISNULL(1));
+
+    a = text_to_cstring(PG_GETARG_TEXT_PP(0));
+    b = text_to_cstring(PG_GETARG_TEXT_PP(1));
+    PG_RETURN_INT32(compare_ulid_text(a, b));
+}
+
+PG_FUNCTION_INFO_V1(ulid_text_sort_key);
+Datum ulid_text_sort_key(PG_FUNCTION_ARGS)
+{
+    char* input = text_to_cstring(PG_GETARG_TEXT_PP(0));
+    bytea* result;
+    ULID u;
+
+    /* '' maps to the empty bytea, which sorts below every 16-byte key */
+    if (input[0] == '\0')
+    {
+        result = (bytea*)palloc(VARHDRSZ);
+        SET_VARSIZE(result, VARHDRSZ);
+        PG_RETURN_BYTEA_P(result);
+    }
+    decode_ulid_text_or_error(input, &u);
+
+    result = (bytea*)palloc(VARHDRSZ + sizeof(u.data));
+    SET_VARSIZE(result, VARHDRSZ + sizeof(u.data));
+    memcpy(VARDATA(result), u.data, sizeof(u.data));
+    PG_RETURN_BYTEA_P(result);
+}
+
+PG_FUNCTION_INFO_V1(ulid_strip_prefix);
+Datum ulid_strip_prefix(PG_FUNCTION_ARGS)
+{
+    char* input = text_to_cstring(PG_GETARG_TEXT_PP(0));
+    char* prefix = text_to_cstring(PG_GETARG_TEXT_PP(1));
+    size_t prefix_len = strlen(prefix);
+    char* result = (char*)palloc(ULID_TEXT_LEN + 1);
+    ULID u;
+
+    if (strncmp(input, prefix, prefix_len) != 0)
+    {
+        ereport(ERROR, (errcode(ERRCODE_INVALID_PARAMETER_VALUE),
+                        errmsg("value \"%s\" does not start with prefix \"%s\"", input, prefix)));
+    }
+    if (strlen(input) - prefix_len != ULID_TEXT_LEN)
+    {
+        ereport(ERROR, (errcode(ERRCODE_INVALID_TEXT_REPRESENTATION),
+                        errmsg("value \"%s\" does not end with a ulid after prefix \"%s\"", input, prefix)));
+    }
+    decode_ulid_text_or_error(input + prefix_len, &u);
+    encode_bytes_to_ulid_text(&u, result);
+    PG_RETURN_TEXT_P(cstring_to_text(result));
+}
+
+PG_FUNCTION_INFO_V1(ulid_extract_prefix);
+Datum ulid_extract_prefix(PG_FUNCTION_ARGS)
+{
+    char* input = text_to_cstring(PG_GETARG_TEXT_PP(0));
+    size_t len = strlen(input);
+    ULID u;
+
+    if (len < ULID_TEXT_LEN)
+    {
+        ereport(ERROR, (errcode(ERRCODE_INVALID_TEXT_REPRESENTATION),
+                        errmsg("value \"%s\" is too short to end with a ulid", input)));
+    }
+    decode_ulid_text_or_error(input + len - ULID_TEXT_LEN, &u);
+    PG_RETURN_TEXT_P(cstring_to_text_with_len(input, (int)(len - ULID_TEXT_LEN)));
+}
+
+/* reason the text is not a spec-valid ULID, or NULL when it is */
+static char* ulid_spec_error(const char* input)
+{
+    int len = pg_mbstrlen(input);
+    const char* p;
+    int pos;
+
+    /* count characters, not bytes, so multibyte input is reported whole */
+    if (len != ULID_TEXT_LEN)
+        return psprintf("wrong length: expected %d characters, got %d", ULID_TEXT_LEN, len);
+    for (p = input, pos = 1; *p; p += pg_mblen(p), pos++)
+    {
+        if (base32_val(*p) < 0)
+            return psprintf("invalid character \"%.*s\" at position %d", pg_mblen(p), p, pos);
+    }
+    if (base32_val(input[0]) > 7)
+        return pstrdup("timestamp overflow: leading character must be 0-7");
+    return NULL;
+}
+
+PG_FUNCTION_INFO_V1(ulid_is_valid);
+Datum ulid_is_valid(PG_FUNCTION_ARGS)
+{
+    char* input = text_to_cstring(PG_GETARG_TEXT_PP(0));
+    PG_RETURN_BOOL(ulid_spec_error(input) == NULL);
+}
+
+PG_FUNCTION_INFO_V1(ulid_validate_and_fix);
+Datum ulid_validate_and_fix(PG_FUNCTION_ARGS)
+{
+    char* input = text_to_cstring(PG_GETARG_TEXT_PP(0));
+    char* cleaned = (char*)palloc(strlen(input) + 1);
+    char* result = (char*)palloc(ULID_TEXT_LEN + 1);
+    char* reason;
+    const char* p;
+    size_t n = 0;
+    ULID u;
+
+    /* drop grouping characters; the decoder handles case and I/L/O */
+    for (p = input; *p; p++)
+    {
+        if (*p == '-' || isspace((unsigned char)*p))
+            continue;
+        cleaned[n++] = *p;
+    }
+    cleaned[n] = '\0';
+
+    /* overflow is not repairable: dropping the high bits changes the timestamp */
+    reason = ulid_spec_error(cleaned);
+    if (reason != NULL)
+    {
+        ereport(ERROR, (errcode(ERRCODE_INVALID_TEXT_REPRESENTATION),
+                        errmsg("cannot repair \"%s\" into a valid ulid: %s", input, reason)));
+    }
+    decode_ulid_text_or_error(cleaned, &u);
+    encode_bytes_to_ulid_text(&u, result);
+    PG_RETURN_TEXT_P(cstring_to_text(result));
+}
+
+PG_FUNCTION_INFO_V1(ulid_overflow_bit);
+Datum ulid_overflow_bit(PG_FUNCTION_ARGS)
+{
+    char* input = text_to_cstring(PG_GETARG_TEXT_PP(0));
+    ULID u;
+
+    /*
+     * 26 chars carry 130 bits but the decoder keeps 128, so a leading digit
+     * above '7' sets timestamp bits that are dropped silently.
+     */
+    decode_ulid_text_or_error(input, &u);
+    PG_RETURN_BOOL(strlen(input) == ULID_TEXT_LEN && base32_val(input[0]) > 7);
+}
+
+PG_FUNCTION_INFO_V1(ulid_parse_error);
+Datum ulid_parse_error(PG_FUNCTION_ARGS)
+{
+    char* input = text_to_cstring(PG_GETARG_TEXT_PP(0));
+    char* reason = ulid_spec_error(input);
+
+    if (reason == NULL)
+        PG_RETURN_NULL();
+    PG_RETURN_TEXT_P(cstring_to_text(reason));
+}
+
+PG_FUNCTION_INFO_V1(ulid_make_ordered);
+Datum ulid_make_ordered(PG_FUNCTION_ARGS)
+{
+    char* prev = text_to_cstring(PG_GETARG_TEXT_PP(0));
+    char* result = (char*)palloc(ULID_TEXT_LEN + 1);
+    int64_t now_ms = get_time_ms();
+    ULID u;
+
+    decode_ulid_text_or_error(prev, &u);
+    if (now_ms > extract_timestamp_ms_from_ulid_bytes(&u))
+    {
+        generate_ulid_with_ts_bytes(&u, now_ms);
+    }
+    else if (!increment_entropy(&u))
+    {
+        ereport(ERROR, (errcode(ERRCODE_NUMERIC_VALUE_OUT_OF_RANGE),
+                        errmsg("ulid entropy overflow: no ULID follows \"%s\" in the same millisecond", prev)));
+    }
+    encode_bytes_to_ulid_text(&u, result);
+    PG_RETURN_TEXT_P(cstring_to_text(result));
+}
+
+PG_FUNCTION_INFO_V1(ulid_is_monotonic_pair);
+Datum ulid_is_monotonic_pair(PG_FUNCTION_ARGS)
+{
+    ULID a;
+    ULID b;
+
+    decode_ulid_text_or_error(text_to_cstring(PG_GETARG_TEXT_PP(0)), &a);
+    decode_ulid_text_or_error(text_to_cstring(PG_GETARG_TEXT_PP(1)), &b);
+
+    /* timestamp leads the byte order, so within a ms this compares entropy */
+    PG_RETURN_BOOL(memcmp(b.data, a.data, 16) > 0);
+}
+
+PG_FUNCTION_INFO_V1(ulid_generate_with_entropy_bytes);
+Datum ulid_generate_with_entropy_bytes(PG_FUNCTION_ARGS)
+{
+    int32 nbytes = PG_GETARG_INT32(0);
+    ULID* r;
+
+    if (nbytes < 0 || nbytes > ULID_ENTROPY_LEN)
+    {
+        ereport(ERROR, (errcode(ERRCODE_INVALID_PARAMETER_VALUE),
+                        errmsg("entropy width must be between 0 and %d bytes, got %d", ULID_ENTROPY_LEN, nbytes),
+                        errhint("Only %d bytes is spec-canonical.", ULID_ENTROPY_LEN)));
+    }
+
+    r = palloc(sizeof(ULID));
+    generate_ulid_with_ts_bytes(r, get_time_ms());
+    /* keep the leading nbytes of entropy random and zero the rest */
+    memset(r->data + ULID_TIMESTAMP_LEN + nbytes, 0, ULID_ENTROPY_LEN - nbytes);
+    PG_RETURN_POINTER(r);
+}
+
+PG_FUNCTION_INFO_V1(ulid_prefix_scan_bounds);
+Datum ulid_prefix_scan_bounds(PG_FUNCTION_ARGS)
+{
+    int64_t lo_ms = timestamptz_to_ulid_ms(PG_GETARG_TIMESTAMPTZ(0));
+    int64_t hi_ms = timestamptz_to_ulid_ms(PG_GETARG_TIMESTAMPTZ(1));
+    char lo_text[ULID_TEXT_LEN + 1];
+    char hi_text[ULID_TEXT_LEN + 1];
+    TupleDesc tupdesc;
+    Datum values[2];
+    bool nulls[2] = {false, false};
+    ULID u;
+
+    if (get_call_result_type(fcinfo, NULL, &tupdesc) != TYPEFUNC_COMPOSITE)
+    {
+        ereport(ERROR, (errcode(ERRCODE_FEATURE_NOT_SUPPORTED),
+                        errmsg("function returning record called in context that cannot accept type record")));
+    }
+    tupdesc = BlessTupleDesc(tupdesc);
+
+    /* floor entropy at the first ms, ceiling entropy at the last */
+    write_ulid_timestamp(&u, lo_ms);
+    memset(u.data + ULID_TIMESTAMP_LEN, 0x00, ULID_ENTROPY_LEN);
+    encode_bytes_to_ulid_text(&u, lo_text);
+    write_ulid_timestamp(&u, hi_ms);
+    memset(u.data + ULID_TIMESTAMP_LEN, 0xFF, ULID_ENTROPY_LEN);
+    encode_bytes_to_ulid_text(&u, hi_text);
+
+    values[0] = PointerGetDatum(cstring_to_text(lo_text));
+    values[1] = PointerGetDatum(cstring_to_text(hi_text));
+    PG_RETURN_DATUM(HeapTupleGetDatum(heap_form_tuple(tupdesc, values, nulls)));
+}
+
+PG_FUNCTION_INFO_V1(ulid_machine_id);
+Datum ulid_machine_id(PG_FUNCTION_ARGS)
+{
+    char* input = text_to_cstring(PG_GETARG_TEXT_PP(0));
+    int32 width = PG_GETARG_INT32(1);
+    bytea* result;
+    ULID u;
+
+    if (width < 1 || width > ULID_ENTROPY_LEN)
+    {
+        ereport(ERROR, (errcode(ERRCODE_INVALID_PARAMETER_VALUE),
+                        errmsg("machine id width must be between 1 and %d, got %d", ULID_ENTROPY_LEN, width)));
+    }
+    decode_ulid_text_or_error(input, &u);
+
+    result = (bytea*)palloc(VARHDRSZ + width);
+    SET_VARSIZE(result, VARHDRSZ + width);
+    memcpy(VARDATA(result), u.data + ULID_TIMESTAMP_LEN, width);
+    PG_RETURN_BYTEA_P(result);
+}
+
+PG_FUNCTION_INFO_V1(ulid_time_only);
+Datum ulid_time_only(PG_FUNCTION_ARGS)
+{
+    char* input = text_to_cstring(PG_GETARG_TEXT_PP(0));
+    int64 granularity_ms = PG_GETARG_INT64(1);
+    char* result = (char*)palloc(ULID_TEXT_LEN + 1);
+    int64_t ts;
+    ULID u;
+
+    if (granularity_ms < 1)
+    {
+        ereport(ERROR, (errcode(ERRCODE_INVALID_PARAMETER_VALUE),
+                        errmsg("granularity must be at least 1 ms, got " INT64_FORMAT, granularity_ms)));
+    }
+    decode_ulid_text_or_error(input, &u);
+
+    ts = extract_timestamp_ms_from_ulid_bytes(&u);
+    write_ulid_timestamp(&u, ts - ts % granularity_ms);
+    memset(u.data + ULID_TIMESTAMP_LEN, 0, ULID_ENTROPY_LEN);
+    encode_bytes_to_ulid_text(&u, result);
+    PG_RETURN_TEXT_P(cstring_to_text(result));
+}
+
+PG_FUNCTION_INFO_V1(ulid_entropy_bits);
+Datum ulid_entropy_bits(PG_FUNCTION_ARGS)
+{
+    char* input = text_to_cstring(PG_GETARG_TEXT_PP(0));
+    int32 from = PG_GETARG_INT32(1);
+    int32 to = PG_GETARG_INT32(2);
+    uint64_t acc = 0;
+    int32 i;
+    ULID u;
+
+    if (from < 0 || to > ULID_ENTROPY_LEN * 8 || from >= to || to - from > 64)
+    {
+        ereport(ERROR, (errcode(ERRCODE_INVALID_PARAMETER_VALUE),
+                        errmsg("invalid entropy bit range [%d, %d)", from, to),
+                        errdetail("Require 0 <= from < to <= %d and at most 64 bits.", ULID_ENTROPY_LEN * 8)));
+    }
+    decode_ulid_text_or_error(input, &u);
+
+    /* bit 0 is the most significant bit of the first entropy byte */
+    for (i = from; i < to; i++)
+        acc = (acc << 1) | ((u.data[ULID_TIMESTAMP_LEN + i / 8] >> (7 - i % 8)) & 1);
+    PG_RETURN_INT64((int64)acc);
+}
+
+PG_FUNCTION_INFO_V1(ulid_parse_strict);
+Datum ulid_parse_strict(PG_FUNCTION_ARGS)
+{
+    char* input = text_to_cstring(PG_GETARG_TEXT_PP(0));
+    ULID* r = palloc(sizeof(ULID));
+    size_t i;
+    bool canonical = strlen(input) == ULID_TEXT_LEN && input[0] <= '7';
+
+    /* only what the encoder emits: uppercase, no I/L/O aliases, no overflow */
+    for (i = 0; canonical && i < ULID_TEXT_LEN; i++)
+        canonical = strchr(base32_alphabet, input[i]) != NULL;
+    if (!canonical || !decode_ulid_text_to_bytes(input, r))
+    {
+        ereport(ERROR, (errcode(ERRCODE_INVALID_TEXT_REPRESENTATION),
+                        errmsg("invalid input syntax for type ulid: \"%s\"", input),
+                        errdetail("Strict parsing accepts only the canonical uppercase 26-character form.")));
+    }
+    PG_RETURN_POINTER(r);
+}
+
+PG_FUNCTION_INFO_V1(ulid_generate_n_at);
+Datum ulid_generate_n_at(PG_FUNCTION_ARGS)
+{
+    FuncCallContext* funcctx;
+    ULID* state;
+    char buf[ULID_TEXT_LEN + 1];
+
+    if (SRF_IS_FIRSTCALL())
+    {
+        int64 ts = PG_GETARG_INT64(0);
+        int32 count = PG_GETARG_INT32(1);
+        MemoryContext oldctx;
+
+        if (ts < 0 || ts > ULID_MAX_TIMESTAMP_MS)
+        {
+            ereport(ERROR, (errcode(ERRCODE_DATETIME_VALUE_OUT_OF_RANGE),
+                            errmsg("timestamp out of range for ulid"),
+                            errdetail("ULID timestamps cover 0 through 2^48-1 milliseconds.")));
+        }
+        funcctx = SRF_FIRSTCALL_INIT();
+        funcctx->max_calls = count > 0 ? (uint64)count : 0;
+
+        /* one random starting point, then +1 per row within the pinned ms */
+        oldctx = MemoryContextSwitchTo(funcctx->multi_call_memory_ctx);
+        state = (ULID*)palloc(sizeof(ULID));
+        MemoryContextSwitchTo(oldctx);
+        generate_ulid_with_ts_bytes(state, ts);
+        funcctx->user_fctx = state;
+    }
+
+    funcctx = SRF_PERCALL_SETUP();
+    state = (ULID*)funcctx->user_fctx;
+    if (funcctx->call_cntr < funcctx->max_calls)
+    {
+        if (funcctx->call_cntr > 0 && !increment_entropy(state))
+        {
+            ereport(ERROR, (errcode(ERRCODE_NUMERIC_VALUE_OUT_OF_RANGE),
+                            errmsg("ulid entropy overflow while generating within one millisecond")));
+        }
+        encode_bytes_to_ulid_text(state, buf);
+        SRF_RETURN_NEXT(funcctx, PointerGetDatum(cstring_to_text(buf)));
+    }
+    SRF_RETURN_DONE(funcctx);
+}
+
+PG_FUNCTION_INFO_V1(ulid_successor_gap);
+Datum ulid_successor_gap(PG_FUNCTION_ARGS)
+{
+    ULID a;
+    ULID b;
+    const ULID* lo;
+    const ULID* hi;
+    uint64_t diff_hi;
+    uint64_t diff_lo;
+
+    decode_ulid_text_or_error(text_to_cstring(PG_GETARG_TEXT_PP(0)), &a);
+    decode_ulid_text_or_error(text_to_cstring(PG_GETARG_TEXT_PP(1)), &b);
+    if (memcmp(a.data, b.data, 16) <= 0)
+    {
+        lo = &a;
+        hi = &b;
+    }
+    else
+    {
+        lo = &b;
+        hi = &a;
+    }
+
+    /* 128-bit hi - lo, then minus one for the values strictly between */
+    diff_lo = ulid_word(hi, 1) - ulid_word(lo, 1);
+    diff_hi = ulid_word(hi, 0) - ulid_word(lo, 0) - (ulid_word(hi, 1) < ulid_word(lo, 1) ? 1 : 0);
+    if (diff_hi == 0 && diff_lo == 0)
+        PG_RETURN_INT64(0);
+    if (diff_hi != 0 || diff_lo - 1 > (uint64_t)PG_INT64_MAX)
+        PG_RETURN_INT64(PG_INT64_MAX);
+    PG_RETURN_INT64((int64)(diff_lo - 1));
+}
@@ -82,6 +82,23 @@ def type_exists(conn_or_cursor, type_name):
         """, (type_name,))
         return conn_or_cursor.fetchone()[0]
 
+# 2022-01-01 00:00:00 UTC
+KNOWN_TS_MS = 1640995200000
+
+# A ULID in canonical text form and its 16 bytes. The text encoding keeps its
+# two pad bits at the end, so the last character of canonical text always has
+# its low two bits clear.
+SAMPLE_ULID = "01ARZ3NDEKTSV4RRFFQ69G5FAR"
+SAMPLE_HEX = "00558f8ead74f59d93187bee64c0af56"
+
+def known_ulid(db, ts_ms=KNOWN_TS_MS):
+    """Return a ULID text with the given embedded timestamp."""
+    return exec_one(db, "SELECT ulid_generate_with_timestamp(%s)::text", (ts_ms,))
+
+def with_entropy(db, ts_ms, entropy_hex):
+    """Return a ULID text with the given timestamp and entropy."""
+    return exec_one(db, "SELECT ulid_set_entropy(%s, %s)", (known_ulid(db, ts_ms), entropy_hex))
+
 @pytest.fixture(scope="session")
 def db():
     """Database connection fixture."""
@@ -123,6 +123,27 @@ def test_timestamptz_casting(db):
     # we at least check it's a datetime object
 
 
+def test_timestamptz_cast_filters_rows(db):
+    """ulid::timestamptz works in WHERE for ulid columns, and via ::ulid for text columns."""
+    with db.cursor() as cur:
+        cur.execute("CREATE TEMP TABLE cast_filter_test (id ulid, id_text text)")
+        try:
+            cur.execute("""
+                INSERT INTO cast_filter_test
+                SELECT u, u::text
+                FROM (SELECT ulid_generate_with_timestamp(ms) AS u
+                      FROM unnest(ARRAY[1704067199999, 1704067200000, 1706745600000]::bigint[]) AS ms) s
+            """)
+            cur.execute("SELECT count(*) FROM cast_filter_test WHERE id::timestamptz >= '2024-01-01 00:00:00+00'")
+            by_ulid = cur.fetchone()[0]
+            cur.execute("SELECT count(*) FROM cast_filter_test WHERE id_text::ulid::timestamptz >= '2024-01-01 00:00:00+00'")
+            by_text = cur.fetchone()[0]
+        finally:
+            cur.execute("DROP TABLE cast_filter_test")
+    assert by_ulid == 2
+    assert by_text == 2
+
+
 def test_uuid_and_back_casting(db):
     """ULID <-> UUID casting should be supported in both directions (if semantically meaningful)."""
     u = exec_one(db, "SELECT ulid()::uuid")
@@ -301,11 +322,14 @@ def test_comprehensive_round_trip_tests(db):
     # 5. Bytea round-trips
     def test_bytea_round_trips():
         """Bytea round-trips (ULID -> bytea -> ULID)."""
-        # Note: Direct bytea::ulid casting is not supported in current implementation
         # Test ULID -> bytea conversion (should work)
         result = exec_one(db, "SELECT %s::ulid::bytea", (test_ulid,))
         assert result is not None
         assert len(result) == 16  # 16 bytes for ULID
+
+        # Test ULID -> bytea -> ULID
+        result = exec_one(db, "SELECT %s::ulid::bytea::ulid::text", (test_ulid,))
+        assert result == exec_one(db, "SELECT %s::ulid::text", (test_ulid,))
         
         # Test that bytea conversion preserves the binary representation
         binary_repr = exec_one(db, "SELECT %s::ulid::bytea", (test_ulid,))
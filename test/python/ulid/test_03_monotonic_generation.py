@@ -105,6 +105,87 @@ def test_batch_monotonic_count_and_uniqueness(db):
     assert total == 10 and uniq == 10, f"ulid_batch produced duplicates: total={total}, uniq={uniq}"
 
 
+def test_batch_is_sorted_in_array_order(db):
+    """ulid_batch(n) elements are strictly increasing in array order."""
+    row = exec_fetchone(
+        db,
+        """
+        WITH b AS (
+            SELECT u, ord FROM unnest(ulid_batch(500)) WITH ORDINALITY AS t(u, ord)
+        ), p AS (
+            SELECT u, lag(u) OVER (ORDER BY ord) AS prev FROM b
+        )
+        SELECT COUNT(*)::int, COUNT(*) FILTER (WHERE prev IS NOT NULL AND prev >= u)::int FROM p
+        """,
+    )
+    assert row == (500, 0), f"ulid_batch output not strictly increasing: {row}"
+
+
+def test_seeded_batch_is_reproducible(db):
+    """ulid_batch(n, seed) returns identical, strictly increasing arrays for the same seed."""
+    first = exec_one(db, "SELECT ulid_batch(100, 42)::text[]")
+    second = exec_one(db, "SELECT ulid_batch(100, 42)::text[]")
+    other = exec_one(db, "SELECT ulid_batch(100, 43)::text[]")
+    assert first == second, "same seed produced different batches"
+    assert first != other, "different seeds produced the same batch"
+    assert len(first) == 100 and first == sorted(first) and len(set(first)) == 100
+
+
+def test_random_batch_is_valid_and_unique(db):
+    """ulid_random_batch(n) elements are valid, unique ULIDs (order not guaranteed)."""
+    row = exec_fetchone(
+        db,
+        """
+        WITH b AS (SELECT unnest(ulid_random_batch(500))::text AS u)
+        SELECT COUNT(*)::int, COUNT(DISTINCT u)::int,
+               bool_and(length(u) = 26 AND ulid_parse(u)::text = u)
+        FROM b
+        """,
+    )
+    assert row == (500, 500, True), f"ulid_random_batch produced invalid or duplicate values: {row}"
+
+
+def test_batch_srf_is_ordered_and_unique(db):
+    """ulid_batch_srf(n) streams n unique ULIDs in strictly increasing order."""
+    row = exec_fetchone(
+        db,
+        """
+        WITH b AS (
+            SELECT u, ord FROM ulid_batch_srf(5000) WITH ORDINALITY AS t(u, ord)
+        ), p AS (
+            SELECT u, lag(u) OVER (ORDER BY ord) AS prev FROM b
+        )
+        SELECT COUNT(*)::int, COUNT(DISTINCT u)::int,
+               COUNT(*) FILTER (WHERE prev IS NOT NULL AND ulid_parse(prev) >= ulid_parse(u))::int
+        FROM p
+        """,
+    )
+    assert row == (5000, 5000, 0), f"ulid_batch_srf output not ordered/unique: {row}"
+    assert exec_one(db, "SELECT COUNT(*)::int FROM ulid_batch_srf(0)") == 0
+
+
+def test_generate_n_at_shares_timestamp_and_is_ordered(db):
+    """ulid_generate_n_at(ts, n) pins every row to ts and keeps rows strictly increasing."""
+    ts = 1640995200000
+    row = exec_fetchone(
+        db,
+        """
+        WITH b AS (
+            SELECT u, ord FROM ulid_generate_n_at(%s, 1000) WITH ORDINALITY AS t(u, ord)
+        ), p AS (
+            SELECT u, lag(u) OVER (ORDER BY ord) AS prev FROM b
+        )
+        SELECT COUNT(*)::int, COUNT(DISTINCT u)::int,
+               bool_and(ulid_timestamp_text(u) = %s),
+               COUNT(*) FILTER (WHERE prev IS NOT NULL AND ulid_parse(prev) >= ulid_parse(u))::int
+        FROM p
+        """,
+        (ts, ts),
+    )
+    assert row == (1000, 1000, True, 0), f"ulid_generate_n_at output not pinned/ordered: {row}"
+    assert exec_one(db, "SELECT COUNT(*)::int FROM ulid_generate_n_at(%s, 0)", (ts,)) == 0
+
+
 def test_timestamp_ordering_between_calls(db):
     """ULID-derived timestamps from consecutive ULID calls should be non-decreasing."""
     row = exec_fetchone(
@@ -244,3 +325,28 @@ def test_final_basic_checks(db):
         "Binary round-trip failed: parsed bytes differ "
         f"{parsed_bytes!r} != {direct_cast_bytes!r}"
     )
+
+
+def ulid_entropy_in_transaction(conn, deterministic, n=3):
+    """Entropy hex of n ulid() calls made in one transaction with the GUC set."""
+    with conn.cursor() as cur:
+        cur.execute("SET LOCAL pg_ulid.deterministic = %s", ("on" if deterministic else "off",))
+        cur.execute("SELECT encode(substring(ulid()::bytea FROM 7), 'hex') FROM generate_series(1, %s)", (n,))
+        rows = [r[0] for r in cur.fetchall()]
+    conn.commit()
+    return rows
+
+
+def test_deterministic_guc_reproducible_entropy():
+    """pg_ulid.deterministic makes ulid() entropy restart per transaction; off stays random."""
+    conn = psycopg2.connect(**DB_CONFIG)
+    try:
+        first = ulid_entropy_in_transaction(conn, True)
+        second = ulid_entropy_in_transaction(conn, True)
+        assert first == second == ["%020x" % i for i in (1, 2, 3)]
+
+        random_a = ulid_entropy_in_transaction(conn, False)
+        random_b = ulid_entropy_in_transaction(conn, False)
+        assert random_a != random_b
+    finally:
+        conn.close()
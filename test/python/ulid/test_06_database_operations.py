@@ -234,3 +234,38 @@ def test_ordering_and_comprehensive_checks(db):
     )
     assert row is not None
     assert all(row), f"Comprehensive checks failed: {row}"
+
+
+def test_ulid_assign_trigger_fills_missing_ids(db):
+    """ulid_assign_trigger() assigns unique, ordered ULIDs to rows inserted without an id."""
+    with db.cursor() as cur:
+        cur.execute("DROP TABLE IF EXISTS ulid_trigger_test")
+        cur.execute("CREATE TABLE ulid_trigger_test (seq serial, id ulid, name text)")
+        cur.execute(
+            """
+            CREATE TRIGGER ulid_trigger_test_assign
+            BEFORE INSERT ON ulid_trigger_test
+            FOR EACH ROW EXECUTE FUNCTION ulid_assign_trigger('id')
+            """
+        )
+        db.commit()
+    try:
+        with db.cursor() as cur:
+            for i in range(5):
+                cur.execute("INSERT INTO ulid_trigger_test (name) VALUES (%s)", (f"row{i}",))
+            cur.execute("INSERT INTO ulid_trigger_test (id, name) VALUES ('01ARZ3NDEKTSV4RRFFQ69G5FAV', 'explicit')")
+            db.commit()
+
+            cur.execute("SELECT id::text FROM ulid_trigger_test WHERE name <> 'explicit' ORDER BY seq")
+            ids = [r[0] for r in cur.fetchall()]
+            cur.execute("SELECT id = '01ARZ3NDEKTSV4RRFFQ69G5FAV'::ulid FROM ulid_trigger_test WHERE name = 'explicit'")
+            explicit = cur.fetchone()[0]
+
+        assert len(ids) == 5 and all(ids), f"Expected 5 assigned ids, got {ids}"
+        assert len(set(ids)) == 5, "Trigger-assigned ULIDs should be unique"
+        assert ids == sorted(ids), "Trigger-assigned ULIDs should follow insertion order"
+        assert explicit is True, "Trigger must not overwrite an explicit id"
+    finally:
+        with db.cursor() as cur:
+            cur.execute("DROP TABLE IF EXISTS ulid_trigger_test")
+            db.commit()
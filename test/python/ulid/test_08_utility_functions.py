@@ -0,0 +1,741 @@
+#!/usr/bin/env python3
+"""
+Pytest-style tests for ULID utility SQL functions.
+
+Run:
+    export PGHOST=localhost
+    export PGDATABASE=testdb
+    export PGUSER=postgres
+    export PGPASSWORD=""
+    pytest -q test/python/ulid/test_08_utility_functions.py
+
+Notes:
+- Uses the session-scoped autocommit connection from conftest so that
+  expected errors don't leave the connection in an aborted transaction.
+- Known ULIDs are built with ulid_generate_with_timestamp() so the embedded
+  timestamp is fixed and assertions can be exact.
+"""
+
+import pytest
+import psycopg2
+from conftest import exec_one, exec_fetchone, KNOWN_TS_MS, SAMPLE_ULID, SAMPLE_HEX, known_ulid, with_entropy
+
+
+def test_ulid_timestamp_iso_defaults_to_utc(db):
+    """A NULL zone renders the timestamp in UTC with a Z suffix."""
+    u = known_ulid(db)
+    assert exec_one(db, "SELECT ulid_timestamp_iso(%s, NULL)", (u,)) == "2022-01-01T00:00:00.000Z"
+    assert exec_one(db, "SELECT ulid_timestamp_iso(%s, 'UTC')", (u,)) == "2022-01-01T00:00:00.000Z"
+
+
+@pytest.mark.parametrize("zone, expected", [
+    ("Asia/Kolkata", "2022-01-01T05:30:00.000+05:30"),
+    ("America/New_York", "2021-12-31T19:00:00.000-05:00"),
+])
+def test_ulid_timestamp_iso_named_zones(db, zone, expected):
+    """IANA zones apply their UTC offset to the rendered timestamp."""
+    u = known_ulid(db)
+    assert exec_one(db, "SELECT ulid_timestamp_iso(%s, %s)", (u, zone)) == expected
+
+
+def test_ulid_timestamp_iso_unknown_zone(db):
+    """Unknown zone names raise an error."""
+    u = known_ulid(db)
+    with pytest.raises(psycopg2.Error):
+        exec_one(db, "SELECT ulid_timestamp_iso(%s, 'Mars/Olympus_Mons')", (u,))
+
+
+def test_ulid_set_entropy_preserves_timestamp(db):
+    """ulid_set_entropy() swaps the entropy bytes and keeps the timestamp."""
+    u = known_ulid(db)
+    entropy = "00112233445566778899"
+    row = exec_fetchone(
+        db,
+        """
+        WITH r AS (SELECT ulid_set_entropy(%s, %s) AS v)
+        SELECT ulid_timestamp_text(v), encode(substring(ulid_parse(v)::bytea FROM 7), 'hex') FROM r
+        """,
+        (u, entropy),
+    )
+    assert row == (KNOWN_TS_MS, entropy)
+
+
+@pytest.mark.parametrize("entropy", ["0011223344556677889", "001122334455667788990", "", "zz112233445566778899"])
+def test_ulid_set_entropy_rejects_bad_entropy(db, entropy):
+    """Entropy must be exactly 20 hex characters."""
+    u = known_ulid(db)
+    with pytest.raises(psycopg2.Error):
+        exec_one(db, "SELECT ulid_set_entropy(%s, %s)", (u, entropy))
+
+
+def test_ulid_entropy_overlap_detects_shared_entropy(db):
+    """ULIDs crafted with the same entropy overlap even when timestamps differ."""
+    entropy = "deadbeefdeadbeefdead"
+    a = exec_one(db, "SELECT ulid_set_entropy(%s, %s)", (known_ulid(db), entropy))
+    b = exec_one(db, "SELECT ulid_set_entropy(%s, %s)", (known_ulid(db, KNOWN_TS_MS + 1000), entropy))
+    assert a != b
+    assert exec_one(db, "SELECT ulid_entropy_overlap(%s, %s)", (a, b)) is True
+
+
+def test_ulid_entropy_overlap_distinct_entropy(db):
+    """Independently generated ULIDs do not overlap."""
+    assert exec_one(db, "SELECT ulid_entropy_overlap(ulid_random()::text, ulid_random()::text)") is False
+
+
+def test_ulid_generate_at_round_trips_time(db):
+    """ulid_generate_at() embeds the timestamptz truncated to milliseconds."""
+    ts_ms = exec_one(db, "SELECT ulid_timestamp_text(ulid_generate_at('2024-06-01 13:45:30.123456+00'))")
+    assert ts_ms == 1717249530123
+    a, b = exec_fetchone(db, "SELECT ulid_generate_at(now()), ulid_generate_at(now())")
+    assert a != b, "ulid_generate_at() should use fresh entropy per call"
+
+
+@pytest.mark.parametrize("ts", ["1969-12-31 23:59:59+00", "12000-01-01 00:00:00+00", "infinity", "-infinity"])
+def test_ulid_generate_at_rejects_out_of_range(db, ts):
+    """Pre-epoch, beyond-48-bit and infinite timestamps are rejected."""
+    with pytest.raises(psycopg2.errors.DatetimeFieldOverflow):
+        exec_one(db, "SELECT ulid_generate_at(%s::timestamptz)", (ts,))
+
+
+def test_ulid_epoch_and_max_value_bytes(db):
+    """The sentinels decode to all-zero bytes and the largest value the text form carries."""
+    lo, hi = exec_fetchone(
+        db, "SELECT encode(ulid_epoch()::ulid::bytea, 'hex'), encode(ulid_max_value()::ulid::bytea, 'hex')"
+    )
+    assert lo == "00" * 16
+    assert hi == "3f" + "ff" * 15
+    assert exec_one(db, "SELECT ulid_max_value()::ulid::text = ulid_max_value()") is True
+
+
+def test_generated_ulids_sort_between_sentinels(db):
+    """Every generated ULID sorts strictly between ulid_epoch() and ulid_max_value()."""
+    ok = exec_one(
+        db,
+        """
+        WITH s AS (
+            SELECT ulid() AS u FROM generate_series(1, 100)
+            UNION ALL
+            SELECT ulid_random() FROM generate_series(1, 100)
+        )
+        SELECT bool_and(ulid_epoch()::ulid < u AND u < ulid_max_value()::ulid) FROM s
+        """,
+    )
+    assert ok is True
+
+
+def test_ulid_to_bytea_and_back(db):
+    """ulid_to_bytea()/ulid_from_bytea() convert losslessly between text and 16 bytes."""
+    known = SAMPLE_ULID
+    hex_bytes = exec_one(db, "SELECT encode(ulid_to_bytea(%s), 'hex')", (known,))
+    assert hex_bytes == SAMPLE_HEX
+    assert exec_one(db, "SELECT ulid_from_bytea(ulid_to_bytea(%s))", (known,)) == known
+    assert exec_one(db, "SELECT ulid_from_bytea(decode(%s, 'hex'))", (hex_bytes,)) == known
+
+
+@pytest.mark.parametrize("hex_bytes", ["", SAMPLE_HEX[:-2], SAMPLE_HEX + "00"])
+def test_ulid_from_bytea_rejects_wrong_length(db, hex_bytes):
+    """ulid_from_bytea() requires exactly 16 bytes."""
+    with pytest.raises(psycopg2.DataError):
+        exec_one(db, "SELECT ulid_from_bytea(decode(%s, 'hex'))", (hex_bytes,))
+
+
+def test_ulid_between_spans_timestamp_boundary(db):
+    """ulid_between() is inclusive and compares timestamp then entropy."""
+    lo = with_entropy(db, KNOWN_TS_MS, "ffffffffffffffffffff")
+    hi = with_entropy(db, KNOWN_TS_MS + 1, "00000000000000000000")
+    cases = [
+        (lo, True),
+        (hi, True),
+        (with_entropy(db, KNOWN_TS_MS, "fffffffffffffffffffe"), False),
+        (with_entropy(db, KNOWN_TS_MS + 1, "00000000000000000001"), False),
+        (with_entropy(db, KNOWN_TS_MS - 1, "ffffffffffffffffffff"), False),
+    ]
+    for x, expected in cases:
+        assert exec_one(db, "SELECT ulid_between(%s, %s, %s)", (lo, hi, x)) is expected, x
+
+
+def test_ulid_between_inside_range(db):
+    """Values strictly inside the range are members; an empty range has none."""
+    lo = with_entropy(db, KNOWN_TS_MS, "00000000000000000000")
+    hi = with_entropy(db, KNOWN_TS_MS + 1000, "00000000000000000000")
+    x = known_ulid(db, KNOWN_TS_MS + 500)
+    assert exec_one(db, "SELECT ulid_between(%s, %s, %s)", (lo, hi, x)) is True
+    assert exec_one(db, "SELECT ulid_between(%s, %s, %s)", (hi, lo, x)) is False
+
+
+def test_ulid_nil_and_is_nil(db):
+    """ulid_nil() is all zero bytes and is the only value ulid_is_nil() accepts."""
+    assert exec_one(db, "SELECT encode(ulid_to_bytea(ulid_nil()), 'hex')") == "00" * 16
+    assert exec_one(db, "SELECT ulid_is_nil(ulid_nil())") is True
+    assert exec_one(db, "SELECT ulid_is_nil(ulid()::text)") is False
+    assert exec_one(db, "SELECT ulid_is_nil(ulid_set_entropy(ulid_nil(), '00000000000000000001'))") is False
+
+
+def test_ulid_shard_is_deterministic_and_ignores_timestamp(db):
+    """Same entropy maps to the same shard regardless of timestamp."""
+    a = with_entropy(db, KNOWN_TS_MS, "0123456789abcdef0123")
+    b = with_entropy(db, KNOWN_TS_MS + 86400000, "0123456789abcdef0123")
+    sa = exec_one(db, "SELECT ulid_shard(%s, 16)", (a,))
+    assert 0 <= sa < 16
+    assert exec_one(db, "SELECT ulid_shard(%s, 16)", (a,)) == sa
+    assert exec_one(db, "SELECT ulid_shard(%s, 16)", (b,)) == sa
+
+
+def test_ulid_shard_distribution(db):
+    """Random ULIDs spread roughly evenly across shards."""
+    with db.cursor() as cur:
+        cur.execute(
+            """
+            SELECT ulid_shard(ulid_random()::text, 4) AS s, COUNT(*)::int
+            FROM generate_series(1, 4000) GROUP BY s ORDER BY s
+            """
+        )
+        counts = dict(cur.fetchall())
+    assert sorted(counts) == [0, 1, 2, 3]
+    assert all(800 <= c <= 1200 for c in counts.values()), counts
+
+
+@pytest.mark.parametrize("n", [0, -1])
+def test_ulid_shard_rejects_non_positive_count(db, n):
+    """The shard count must be positive."""
+    with pytest.raises(psycopg2.DataError):
+        exec_one(db, "SELECT ulid_shard(ulid()::text, %s)", (n,))
+
+
+def test_ulid_first_n_bytes_timestamp_prefix(db):
+    """The first 6 bytes are the big-endian millisecond timestamp."""
+    u = known_ulid(db)
+    prefix = exec_one(db, "SELECT encode(ulid_first_n_bytes(%s, 6), 'hex')", (u,))
+    assert prefix == format(KNOWN_TS_MS, "012x")
+    full = exec_one(db, "SELECT encode(ulid_first_n_bytes(%s, 16), 'hex')", (u,))
+    assert full == exec_one(db, "SELECT encode(ulid_to_bytea(%s), 'hex')", (u,))
+
+
+def test_ulid_first_n_bytes_preserves_order(db):
+    """Prefixes are non-decreasing when rows are ordered by the full ULID."""
+    violations = exec_one(
+        db,
+        """
+        WITH s AS (SELECT ulid_random()::text AS u FROM generate_series(1, 200)),
+        p AS (
+            SELECT ulid_first_n_bytes(u, 8) AS pre,
+                   lag(ulid_first_n_bytes(u, 8)) OVER (ORDER BY ulid_parse(u)) AS prev
+            FROM s
+        )
+        SELECT COUNT(*)::int FROM p WHERE prev > pre
+        """,
+    )
+    assert violations == 0
+
+
+@pytest.mark.parametrize("n", [0, 17, -1])
+def test_ulid_first_n_bytes_rejects_bad_count(db, n):
+    """n must be between 1 and 16."""
+    with pytest.raises(psycopg2.DataError):
+        exec_one(db, "SELECT ulid_first_n_bytes(ulid()::text, %s)", (n,))
+
+
+def test_ulid_strip_prefix(db):
+    """A matching prefix is removed and the canonical ULID returned."""
+    u = known_ulid(db)
+    assert exec_one(db, "SELECT ulid_strip_prefix(%s, 'order_')", ("order_" + u,)) == u
+    assert exec_one(db, "SELECT ulid_strip_prefix(%s, 'order_')", ("order_" + u.lower(),)) == u
+    assert exec_one(db, "SELECT ulid_strip_prefix(%s, '')", (u,)) == u
+
+
+@pytest.mark.parametrize("value,prefix", [
+    ("user_{u}", "order_"),
+    ("order{u}", "order_"),
+    ("order_{u}X", "order_"),
+    ("order_", "order_"),
+    ("order_01ARZ3NDEKTSV4RRFFQ69G5FAV", "order_0"),
+])
+def test_ulid_strip_prefix_rejects_mismatch(db, value, prefix):
+    """Mismatched prefixes and remainders that are not exactly one ULID raise errors."""
+    u = known_ulid(db)
+    with pytest.raises(psycopg2.DataError):
+        exec_one(db, "SELECT ulid_strip_prefix(%s, %s)", (value.format(u=u), prefix))
+
+
+def test_ulid_extract_prefix(db):
+    """The prefix before the trailing ULID is returned."""
+    u = known_ulid(db)
+    assert exec_one(db, "SELECT ulid_extract_prefix(%s)", ("order_" + u,)) == "order_"
+    assert exec_one(db, "SELECT ulid_extract_prefix(%s)", (u,)) == ""
+    with pytest.raises(psycopg2.DataError):
+        exec_one(db, "SELECT ulid_extract_prefix('order_')")
+
+
+def test_ulid_to_jsonb_fields(db):
+    """ulid_to_jsonb() exposes each component under its documented key."""
+    u = with_entropy(db, KNOWN_TS_MS, "00112233445566778899")
+    row = exec_fetchone(
+        db,
+        """
+        WITH j AS (SELECT ulid_to_jsonb(lower(%s)) AS d)
+        SELECT d->>'ulid', (d->>'timestamp_ms')::bigint, d->>'timestamp_iso', d->>'entropy_hex' FROM j
+        """,
+        (u,),
+    )
+    assert row == (u, KNOWN_TS_MS, "2022-01-01T00:00:00.000Z", "00112233445566778899")
+
+
+def test_ulid_to_jsonb_rejects_invalid(db):
+    """Invalid input raises instead of producing a document."""
+    with pytest.raises(psycopg2.errors.InvalidTextRepresentation):
+        exec_one(db, "SELECT ulid_to_jsonb('not-a-ulid')")
+
+
+@pytest.mark.parametrize("value", [None, "", "00000000000000000000000000"])
+def test_ulid_coalesce_nil_generates(db, value):
+    """NULL, empty string and the nil ULID are replaced with a fresh ULID."""
+    out = exec_one(db, "SELECT ulid_coalesce_nil(%s)", (value,))
+    assert out is not None and len(out) == 26
+    assert exec_one(db, "SELECT ulid_is_nil(%s)", (out,)) is False
+
+
+def test_ulid_coalesce_nil_keeps_valid_input(db):
+    """A valid non-nil ULID is returned unchanged; invalid input raises."""
+    u = known_ulid(db)
+    assert exec_one(db, "SELECT ulid_coalesce_nil(%s)", (u,)) == u
+    with pytest.raises(psycopg2.DataError):
+        exec_one(db, "SELECT ulid_coalesce_nil('not-a-ulid')")
+
+
+@pytest.mark.parametrize("dirty", [
+    "01arz3ndektsv4rrffq69g5far",
+    "01ARZ3ND-EKTS-V4RR-FFQ6-9G5F-AR",
+    " 01ARZ3NDEKTSV4RRFFQ69G5FAR\n",
+    "O1ARZ3NDEKTSV4RRFFQ69G5FAR",
+    "0IARZ3NDEKTSV4RRFFQ69G5FAR",
+    "0lARZ3NDEKTSV4RRFFQ69G5FAR",
+])
+def test_ulid_validate_and_fix_repairs(db, dirty):
+    """Case, Crockford look-alikes, whitespace and hyphens are repaired."""
+    assert exec_one(db, "SELECT ulid_validate_and_fix(%s)", (dirty,)) == SAMPLE_ULID
+
+
+@pytest.mark.parametrize("dirty", [
+    "",
+    "01ARZ3NDEKTSV4RRFFQ69G5FA",
+    "01ARZ3NDEKTSV4RRFFQ69G5FAVX",
+    "01ARZ3NDEKTSV4RRFFQ69G5FAU",
+    "8ZZZZZZZZZZZZZZZZZZZZZZZZZ",
+    "Z1ARZ3NDEKTSV4RRFFQ69G5FAR",
+])
+def test_ulid_validate_and_fix_rejects_unrepairable(db, dirty):
+    """Wrong lengths, characters outside the alphabet and timestamp overflow cannot be repaired."""
+    with pytest.raises(psycopg2.errors.InvalidTextRepresentation):
+        exec_one(db, "SELECT ulid_validate_and_fix(%s)", (dirty,))
+
+
+@pytest.mark.parametrize("text", ["8ZZZZZZZZZZZZZZZZZZZZZZZZZ", "90000000000000000000000000", "Z1ARZ3NDEKTSV4RRFFQ69G5FAV", "z1arz3ndektsv4rrffq69g5fav"])
+def test_ulid_overflow_bit_detects_overflow(db, text):
+    """A leading character above '7' sets bits beyond the 48-bit timestamp."""
+    assert exec_one(db, "SELECT ulid_overflow_bit(%s)", (text,)) is True
+
+
+def test_ulid_overflow_bit_normal_ulids(db):
+    """Generated ULIDs and the spec maximum do not overflow."""
+    assert exec_one(db, "SELECT ulid_overflow_bit(ulid_random()::text)") is False
+    assert exec_one(db, "SELECT ulid_overflow_bit('01ARZ3NDEKTSV4RRFFQ69G5FAV')") is False
+    assert exec_one(db, "SELECT ulid_overflow_bit('7ZZZZZZZZZZZZZZZZZZZZZZZZZ')") is False
+
+
+def test_ulid_overflow_bit_rejects_invalid(db):
+    """Text that is not a ULID at all raises rather than returning false."""
+    with pytest.raises(psycopg2.errors.InvalidTextRepresentation):
+        exec_one(db, "SELECT ulid_overflow_bit('not-a-ulid')")
+
+
+def test_ulid_parse_error_null_when_valid(db):
+    """Spec-valid input, in any case, reports no error."""
+    assert exec_one(db, "SELECT ulid_parse_error('01ARZ3NDEKTSV4RRFFQ69G5FAV')") is None
+    assert exec_one(db, "SELECT ulid_parse_error('01arz3ndektsv4rrffq69g5fav')") is None
+    assert exec_one(db, "SELECT ulid_parse_error(ulid_random()::text)") is None
+
+
+@pytest.mark.parametrize("text,reason", [
+    ("", "wrong length: expected 26 characters, got 0"),
+    ("01ARZ3NDEKTSV4RRFFQ69G5FA", "wrong length: expected 26 characters, got 25"),
+    ("01ARZ3NDEKTSV4RRFFQ69G5FAVX", "wrong length: expected 26 characters, got 27"),
+    ("01ARZ3NDEKTSV4RRFFQ69G5FAU", 'invalid character "U" at position 26'),
+    ("01ARZ3-DEKTSV4RRFFQ69G5FAV", 'invalid character "-" at position 7'),
+    ("01ARZ3NDEKTSV4RRFFQ69G5Fé", "wrong length: expected 26 characters, got 25"),
+    ("01ARZ3NDEKTSV4RRFFQ69G5FAé", 'invalid character "é" at position 26'),
+    ("0éARZ3NDEKTSV4RRFFQ69G5FAV", 'invalid character "é" at position 2'),
+    ("8ZZZZZZZZZZZZZZZZZZZZZZZZZ", "timestamp overflow: leading character must be 0-7"),
+])
+def test_ulid_parse_error_reasons(db, text, reason):
+    """Each invalid category yields its own human-readable reason."""
+    assert exec_one(db, "SELECT ulid_parse_error(%s)", (text,)) == reason
+
+
+@pytest.mark.parametrize("text", [
+    "01ARZ3NDEKTSV4RRFFQ69G5FAV",
+    "01arz3ndektsv4rrffq69g5fav",
+    "7ZZZZZZZZZZZZZZZZZZZZZZZZZ",
+    "",
+    "01ARZ3NDEKTSV4RRFFQ69G5FA",
+    "01ARZ3NDEKTSV4RRFFQ69G5FAU",
+    "01ARZ3NDEKTSV4RRFFQ69G5FAé",
+    "8ZZZZZZZZZZZZZZZZZZZZZZZZZ",
+])
+def test_ulid_is_valid_agrees_with_parse_error(db, text):
+    """ulid_is_valid is true exactly when ulid_parse_error reports nothing."""
+    assert exec_one(db, "SELECT ulid_is_valid(%s) = (ulid_parse_error(%s) IS NULL)", (text, text)) is True
+
+
+def test_ulid_make_ordered_after_past_ulid(db):
+    """A ULID from the past is followed by one at the current time."""
+    prev = known_ulid(db)
+    nxt = exec_one(db, "SELECT ulid_make_ordered(%s)", (prev,))
+    assert exec_one(db, "SELECT ulid_text_cmp(%s, %s)", (nxt, prev)) == 1
+    assert exec_one(db, "SELECT ulid_timestamp_text(%s)", (nxt,)) > KNOWN_TS_MS
+
+
+def test_ulid_make_ordered_after_future_ulid(db):
+    """A ULID from the future keeps its timestamp and gets the next entropy."""
+    future_ms = exec_one(db, "SELECT (extract(epoch FROM now() + interval '1 day') * 1000)::bigint")
+    prev = with_entropy(db, future_ms, "00000000000000000041")
+    nxt = exec_one(db, "SELECT ulid_make_ordered(%s)", (prev,))
+    assert exec_one(db, "SELECT ulid_text_cmp(%s, %s)", (nxt, prev)) == 1
+    assert nxt == with_entropy(db, future_ms, "00000000000000000042")
+
+
+def test_ulid_make_ordered_chain_is_strictly_increasing(db):
+    """Repeated calls within one millisecond still strictly increase."""
+    prev = exec_one(db, "SELECT ulid_random()::text")
+    for _ in range(100):
+        nxt = exec_one(db, "SELECT ulid_make_ordered(%s)", (prev,))
+        assert exec_one(db, "SELECT ulid_text_cmp(%s, %s)", (nxt, prev)) == 1
+        prev = nxt
+
+
+def test_ulid_make_ordered_entropy_overflow(db):
+    """Nothing follows a ULID whose entropy is already all ones in its millisecond."""
+    with pytest.raises(psycopg2.errors.NumericValueOutOfRange):
+        exec_one(db, "SELECT ulid_make_ordered('7ZZZZZZZZZZZZZZZZZZZZZZZZZ')")
+
+
+def test_ulid_is_monotonic_pair_valid_successors(db):
+    """A later timestamp, or the same timestamp with larger entropy, follows."""
+    a = with_entropy(db, KNOWN_TS_MS, "ffffffffffffffffffff")
+    b = with_entropy(db, KNOWN_TS_MS + 1, "00000000000000000000")
+    assert exec_one(db, "SELECT ulid_is_monotonic_pair(%s, %s)", (a, b)) is True
+    a = with_entropy(db, KNOWN_TS_MS, "00000000000000000001")
+    b = with_entropy(db, KNOWN_TS_MS, "00000000000000000002")
+    assert exec_one(db, "SELECT ulid_is_monotonic_pair(%s, %s)", (a, b)) is True
+
+
+def test_ulid_is_monotonic_pair_same_id(db):
+    """An ID does not follow itself, whatever its case."""
+    u = known_ulid(db)
+    assert exec_one(db, "SELECT ulid_is_monotonic_pair(%s, %s)", (u, u)) is False
+    assert exec_one(db, "SELECT ulid_is_monotonic_pair(%s, lower(%s))", (u, u)) is False
+
+
+def test_ulid_is_monotonic_pair_out_of_order(db):
+    """Earlier timestamps and smaller entropy in the same ms are rejected."""
+    a = with_entropy(db, KNOWN_TS_MS + 1, "00000000000000000000")
+    b = with_entropy(db, KNOWN_TS_MS, "ffffffffffffffffffff")
+    assert exec_one(db, "SELECT ulid_is_monotonic_pair(%s, %s)", (a, b)) is False
+    a = with_entropy(db, KNOWN_TS_MS, "00000000000000000002")
+    b = with_entropy(db, KNOWN_TS_MS, "00000000000000000001")
+    assert exec_one(db, "SELECT ulid_is_monotonic_pair(%s, %s)", (a, b)) is False
+
+
+def test_ulid_is_monotonic_pair_rejects_invalid(db):
+    """Invalid input raises rather than comparing."""
+    with pytest.raises(psycopg2.errors.InvalidTextRepresentation):
+        exec_one(db, "SELECT ulid_is_monotonic_pair('bogus', %s)", (known_ulid(db),))
+
+
+def test_ulid_within_last_recent(db):
+    """A ULID made a minute ago is within the last hour."""
+    assert exec_one(db, """
+        SELECT ulid_within_last(ulid_generate_at(now() - interval '1 minute'), interval '1 hour')
+    """) is True
+    assert exec_one(db, "SELECT ulid_within_last(ulid()::text, interval '1 second')") is True
+
+
+def test_ulid_within_last_old(db):
+    """A ULID from 2022 is not within the last hour."""
+    assert exec_one(db, "SELECT ulid_within_last(%s, interval '1 hour')", (known_ulid(db),)) is False
+    assert exec_one(db, """
+        SELECT ulid_within_last(ulid_generate_at(now() - interval '2 hours'), interval '1 hour')
+    """) is False
+
+
+def test_ulid_within_last_rejects_invalid(db):
+    """Invalid ULID text raises."""
+    with pytest.raises(psycopg2.errors.InvalidTextRepresentation):
+        exec_one(db, "SELECT ulid_within_last('bogus', interval '1 hour')")
+
+
+def test_ulid_age_bucket_today_and_older(db):
+    """A fresh ULID is from today and one from 2022 is older."""
+    assert exec_one(db, "SELECT ulid_age_bucket(ulid()::text)") == "today"
+    assert exec_one(db, "SELECT ulid_age_bucket(%s)", (known_ulid(db),)) == "older"
+    assert exec_one(db, """
+        SELECT ulid_age_bucket(ulid_generate_at(date_trunc('month', now()) - interval '1 millisecond'))
+    """) in ("this_week", "older")
+
+
+@pytest.mark.parametrize("unit,label", [("week", "this_week"), ("month", "this_month")])
+def test_ulid_age_bucket_week_and_month(db, unit, label):
+    """The first millisecond of the current week/month lands in its bucket."""
+    start_is_today, start_in_week = exec_fetchone(db, f"""
+        SELECT date_trunc('{unit}', now()) = date_trunc('day', now()),
+               date_trunc('{unit}', now()) >= date_trunc('week', now())
+    """)
+    if start_is_today or (unit == "month" and start_in_week):
+        pytest.skip(f"the {unit} started within a narrower bucket today")
+    got = exec_one(db, f"SELECT ulid_age_bucket(ulid_generate_at(date_trunc('{unit}', now())))")
+    assert got == label
+
+
+@pytest.mark.parametrize("width", [0, 4, 10])
+def test_ulid_random_entropy_width(db, width):
+    """Entropy past the requested width is zero; 10 bytes is the default behaviour."""
+    tail = exec_one(db, "SELECT encode(substring(ulid_random(%s)::bytea FROM %s), 'hex')", (width, 7 + width))
+    assert tail == "00" * (10 - width)
+    a, b = exec_fetchone(db, "SELECT ulid_random(10)::text, ulid_random(10)::text")
+    assert a != b
+
+
+@pytest.mark.parametrize("width", [-1, 11, 16])
+def test_ulid_random_rejects_invalid_width(db, width):
+    """Widths outside 0-10 bytes are rejected."""
+    with pytest.raises(psycopg2.errors.InvalidParameterValue):
+        exec_one(db, "SELECT ulid_random(%s)", (width,))
+
+
+def test_ulid_prefix_scan_bounds_selects_range(db):
+    """BETWEEN lo AND hi returns exactly the rows created inside the interval."""
+    with db.cursor() as cur:
+        cur.execute("CREATE TEMP TABLE scan_bounds_test (id text PRIMARY KEY, ts_ms bigint)")
+        try:
+            cur.execute("""
+                INSERT INTO scan_bounds_test
+                SELECT ulid_set_entropy(ulid_generate_with_timestamp(%s + o)::text, e), %s + o
+                FROM unnest(ARRAY[-1, 0, 0, 500, 999, 999, 1000]) AS o,
+                     unnest(ARRAY['00000000000000000000', 'ffffffffffffffffffff', '0123456789abcdef0123']) AS e
+                ON CONFLICT DO NOTHING
+            """, (KNOWN_TS_MS, KNOWN_TS_MS))
+            cur.execute("""
+                SELECT count(*) FILTER (WHERE id BETWEEN b.lo AND b.hi),
+                       count(*) FILTER (WHERE ts_ms BETWEEN %s AND %s + 999)
+                FROM scan_bounds_test,
+                     ulid_prefix_scan_bounds(to_timestamp(%s / 1000.0), to_timestamp((%s + 999) / 1000.0)) AS b
+            """, (KNOWN_TS_MS, KNOWN_TS_MS, KNOWN_TS_MS, KNOWN_TS_MS))
+            in_bounds, expected = cur.fetchone()
+        finally:
+            cur.execute("DROP TABLE scan_bounds_test")
+    assert expected == 9
+    assert in_bounds == expected
+
+
+def test_ulid_prefix_scan_bounds_entropy(db):
+    """lo carries zero entropy and hi carries all-ones entropy."""
+    lo, hi = exec_fetchone(db, "SELECT lo, hi FROM ulid_prefix_scan_bounds(to_timestamp(0), to_timestamp(0))")
+    assert lo == "00000000000000000000000000"
+    assert hi == "0000000003ZZZZZZZZZZZZZZZW"
+
+
+@pytest.mark.parametrize("n", [1, 3, 100])
+def test_ulid_values_body(db, n):
+    """The VALUES body holds n quoted, increasing ULIDs separated by commas."""
+    body = exec_one(db, "SELECT ulid_values(%s)", (n,))
+    assert body.startswith("('") and body.endswith("')")
+    items = body[2:-2].split("'),('")
+    assert len(items) == n
+    assert all(exec_one(db, "SELECT ulid_is_valid(%s)", (u,)) for u in items)
+    assert items == sorted(items)
+    assert exec_one(db, f"SELECT count(*) FROM (VALUES {body}) AS v(id)") == n
+
+
+@pytest.mark.parametrize("n", [0, -1, 10001])
+def test_ulid_values_rejects_bad_count(db, n):
+    """n outside 1-10000 is rejected."""
+    with pytest.raises(psycopg2.errors.InvalidParameterValue):
+        exec_one(db, "SELECT ulid_values(%s)", (n,))
+
+
+@pytest.mark.parametrize("tag_hex", ["a5", "c0ffee", "0102030405060708090a"])
+def test_ulid_machine_id_round_trip(db, tag_hex):
+    """A tag written into the leading entropy bytes reads back unchanged."""
+    width = len(tag_hex) // 2
+    rand_hex = exec_one(db, "SELECT encode(substring(ulid_random()::bytea FROM 7 FOR %s), 'hex')", (10 - width,))
+    u = with_entropy(db, KNOWN_TS_MS, tag_hex + rand_hex)
+    assert bytes(exec_one(db, "SELECT ulid_machine_id(%s, %s)", (u, width))) == bytes.fromhex(tag_hex)
+
+
+@pytest.mark.parametrize("width", [0, 11, -3])
+def test_ulid_machine_id_rejects_bad_width(db, width):
+    """Widths outside the 10 entropy bytes are rejected."""
+    with pytest.raises(psycopg2.errors.InvalidParameterValue):
+        exec_one(db, "SELECT ulid_machine_id(%s, %s)", (known_ulid(db), width))
+
+
+def test_ulid_time_only_same_second_shares_key(db):
+    """ULIDs within one second map to the same key; the next second does not."""
+    keys = [
+        exec_one(db, "SELECT ulid_time_only(%s)", (known_ulid(db, KNOWN_TS_MS + d),))
+        for d in (0, 1, 999, 1000)
+    ]
+    assert keys[0] == keys[1] == keys[2] != keys[3]
+    assert keys[0] == with_entropy(db, KNOWN_TS_MS, "00000000000000000000")
+
+
+def test_ulid_time_only_custom_granularity(db):
+    """The timestamp is floored to the given granularity."""
+    u = known_ulid(db, KNOWN_TS_MS + 59999)
+    assert exec_one(db, "SELECT ulid_timestamp_text(ulid_time_only(%s, 60000))", (u,)) == KNOWN_TS_MS
+    assert exec_one(db, "SELECT ulid_timestamp_text(ulid_time_only(%s, 1))", (u,)) == KNOWN_TS_MS + 59999
+    with pytest.raises(psycopg2.errors.InvalidParameterValue):
+        exec_one(db, "SELECT ulid_time_only(%s, 0)", (u,))
+
+
+@pytest.mark.parametrize("lo,hi,expected", [
+    (0, 8, 0x12),
+    (0, 4, 0x1),
+    (4, 12, 0x23),
+    (16, 48, 0x56789ABC),
+    (72, 80, 0xF0),
+    (79, 80, 0),
+    (0, 64, 0x123456789ABCDEF0),
+    (16, 80, 0x56789ABCDEF0F0F0),
+])
+def test_ulid_entropy_bits_known_pattern(db, lo, hi, expected):
+    """Bit ranges read big-endian from the start of the entropy."""
+    u = with_entropy(db, KNOWN_TS_MS, "123456789abcdef0f0f0")
+    assert exec_one(db, "SELECT ulid_entropy_bits(%s, %s, %s)", (u, lo, hi)) == expected
+
+
+def test_ulid_entropy_bits_full_width_wraps(db):
+    """A 64-bit range with the top bit set comes back as a negative bigint."""
+    u = with_entropy(db, KNOWN_TS_MS, "ffffffffffffffffffff")
+    assert exec_one(db, "SELECT ulid_entropy_bits(%s, 0, 64)", (u,)) == -1
+    assert exec_one(db, "SELECT ulid_entropy_bits(%s, 1, 64)", (u,)) == 2 ** 63 - 1
+
+
+@pytest.mark.parametrize("lo,hi", [(-1, 8), (8, 8), (10, 4), (0, 81), (0, 65)])
+def test_ulid_entropy_bits_rejects_bad_range(db, lo, hi):
+    """Ranges outside the entropy, empty, reversed or wider than 64 bits are rejected."""
+    with pytest.raises(psycopg2.errors.InvalidParameterValue):
+        exec_one(db, "SELECT ulid_entropy_bits(%s, %s, %s)", (known_ulid(db), lo, hi))
+
+
+def test_ulid_compact_known_value(db):
+    """The compact form is unpadded base64url of the 16 bytes."""
+    assert exec_one(db, "SELECT ulid_compact(%s)", (SAMPLE_ULID,)) == "AFWPjq109Z2TGHvuZMCvVg"
+    assert exec_one(db, "SELECT ulid_expand('AFWPjq109Z2TGHvuZMCvVg')") == SAMPLE_ULID
+
+
+def test_ulid_compact_round_trip(db):
+    """compact and expand invert each other and always give 22 characters."""
+    ok = exec_one(db, """
+        SELECT bool_and(length(ulid_compact(u)) = 22 AND ulid_expand(ulid_compact(u)) = u)
+        FROM (SELECT ulid_random()::text AS u FROM generate_series(1, 200)) s
+    """)
+    assert ok is True
+
+
+@pytest.mark.parametrize("compact", [
+    "",
+    "AFWPjq109Z2TGHvuZMCvV",
+    "AFWPjq109Z2TGHvuZMCvVgg",
+    "AFWPjq109Z2TGHvuZMCvVg==",
+    "AFWPjq109Z2TGHvu+MCv/g",
+    "AFWPjq109Z2TGHvuZMCvVh",
+])
+def test_ulid_expand_rejects_malformed(db, compact):
+    """Wrong length, padding, non-url alphabet and non-canonical trailing bits are rejected."""
+    with pytest.raises(psycopg2.errors.InvalidTextRepresentation):
+        exec_one(db, "SELECT ulid_expand(%s)", (compact,))
+
+
+def test_ulid_to_interval_since_epoch(db):
+    """The interval equals the embedded ms and lands back on the ULID time from the epoch."""
+    u = known_ulid(db, KNOWN_TS_MS + 123)
+    ms, lands = exec_fetchone(db, """
+        SELECT (extract(epoch FROM ulid_to_interval_since_epoch(%s)) * 1000)::bigint,
+               to_timestamp(0) + ulid_to_interval_since_epoch(%s) = to_timestamp(%s / 1000.0)
+    """, (u, u, KNOWN_TS_MS + 123))
+    assert ms == KNOWN_TS_MS + 123
+    assert lands is True
+    assert exec_one(db, "SELECT ulid_to_interval_since_epoch(ulid_epoch())::text") == "00:00:00"
+
+
+def test_ulid_parse_strict_accepts_canonical(db):
+    """Canonical uppercase input parses to the same value as ulid_parse."""
+    u = exec_one(db, "SELECT ulid_random()::text")
+    for text in (u, "01ARZ3NDEKTSV4RRFFQ69G5FAV", "7ZZZZZZZZZZZZZZZZZZZZZZZZZ"):
+        assert exec_one(db, "SELECT ulid_parse_strict(%s) = ulid_parse(%s)", (text, text)) is True
+
+
+@pytest.mark.parametrize("text", [
+    "01arz3ndektsv4rrffq69g5fav",
+    "01ARZ3NDEKTSV4RRFFQ69G5FAv",
+    "0IARZ3NDEKTSV4RRFFQ69G5FAV",
+    "O1ARZ3NDEKTSV4RRFFQ69G5FAV",
+    "01ARZ3NDEKTSV4RRFFQ69G5FA",
+    "8ZZZZZZZZZZZZZZZZZZZZZZZZZ",
+])
+def test_ulid_parse_strict_rejects_non_canonical(db, text):
+    """Lowercase, aliases, short input and overflow are rejected even though ulid_parse accepts them."""
+    assert exec_one(db, "SELECT ulid_parse(%s) IS NOT NULL", (text,)) is True
+    with pytest.raises(psycopg2.errors.InvalidTextRepresentation):
+        exec_one(db, "SELECT ulid_parse_strict(%s)", (text,))
+
+
+@pytest.mark.parametrize("bucket,expected", [
+    ("minute", "2024-06-01T13:45"),
+    ("hour", "2024-06-01T13"),
+    ("day", "2024-06-01"),
+    ("HOUR", "2024-06-01T13"),
+])
+def test_ulid_to_time_bucket_key(db, bucket, expected):
+    """Keys are formatted in UTC at the named granularity, whatever the session zone."""
+    u = exec_one(db, "SELECT ulid_generate_at('2024-06-01 13:45:30.123+00')")
+    with db.cursor() as cur:
+        cur.execute("SET TIME ZONE 'America/New_York'")
+        try:
+            cur.execute("SELECT ulid_to_time_bucket_key(%s, %s)", (u, bucket))
+            got = cur.fetchone()[0]
+        finally:
+            cur.execute("RESET TIME ZONE")
+    assert got == expected
+
+
+def test_ulid_to_time_bucket_key_rejects_unknown_bucket(db):
+    """Only minute, hour and day are accepted."""
+    with pytest.raises(psycopg2.errors.InvalidParameterValue):
+        exec_one(db, "SELECT ulid_to_time_bucket_key(%s, 'week')", (known_ulid(db),))
+
+
+def test_ulid_successor_gap_adjacent_and_small(db):
+    """Adjacent and equal ULIDs have no gap; small gaps count the values in between."""
+    a = with_entropy(db, KNOWN_TS_MS, "00000000000000000001")
+    b = with_entropy(db, KNOWN_TS_MS, "00000000000000000002")
+    c = with_entropy(db, KNOWN_TS_MS, "00000000000000000102")
+    assert exec_one(db, "SELECT ulid_successor_gap(%s, %s)", (a, b)) == 0
+    assert exec_one(db, "SELECT ulid_successor_gap(%s, %s)", (a, a)) == 0
+    assert exec_one(db, "SELECT ulid_successor_gap(%s, %s)", (a, c)) == 256
+    assert exec_one(db, "SELECT ulid_successor_gap(%s, %s)", (c, a)) == 256
+
+
+def test_ulid_successor_gap_borrows_across_words(db):
+    """The subtraction carries across the 64-bit halves of the value."""
+    a = with_entropy(db, KNOWN_TS_MS, "0000ffffffffffffffff")
+    b = with_entropy(db, KNOWN_TS_MS, "00010000000000000005")
+    assert exec_one(db, "SELECT ulid_successor_gap(%s, %s)", (a, b)) == 5
+
+
+def test_ulid_successor_gap_saturates(db):
+    """Gaps beyond the bigint range saturate at its maximum."""
+    assert exec_one(db, "SELECT ulid_successor_gap(ulid_epoch(), ulid_max_value())") == 2 ** 63 - 1
+    a = with_entropy(db, KNOWN_TS_MS, "00000000000000000000")
+    b = known_ulid(db, KNOWN_TS_MS + 1)
+    assert exec_one(db, "SELECT ulid_successor_gap(%s, %s)", (a, b)) == 2 ** 63 - 1
@@ -0,0 +1,381 @@
+#!/usr/bin/env python3
+"""
+Pytest-style tests for ULID aggregates and array helpers.
+
+Run:
+    pytest -q test/python/ulid/test_09_aggregates_and_arrays.py
+
+Notes:
+- Text ULIDs are compared by their 16-byte value, so results must not depend
+  on the collation of the column they are stored in. Tests mix upper and
+  lower case input to make text order and binary order disagree.
+"""
+
+import pytest
+import psycopg2
+from conftest import exec_one, exec_fetchone, KNOWN_TS_MS, SAMPLE_ULID, SAMPLE_HEX, known_ulid, with_entropy
+
+
+def non_c_collation(db):
+    """Name of an available non-C collation, or None."""
+    return exec_one(
+        db,
+        """
+        SELECT collname FROM pg_collation
+        WHERE collname IN ('en_US.utf8', 'en_US.UTF-8', 'en-US-x-icu', 'und-x-icu')
+        ORDER BY collname LIMIT 1
+        """,
+    )
+
+
+@pytest.fixture
+def mixed_case_table(db):
+    """A temp table of ULIDs where text order disagrees with binary order."""
+    lo = known_ulid(db, KNOWN_TS_MS)
+    mid = known_ulid(db, KNOWN_TS_MS + 1000).lower()
+    hi = known_ulid(db, KNOWN_TS_MS + 2000)
+    with db.cursor() as cur:
+        cur.execute("DROP TABLE IF EXISTS ulid_agg_test")
+        cur.execute("CREATE TABLE ulid_agg_test (grp int, c_id text COLLATE \"C\", id text)")
+        cur.executemany(
+            "INSERT INTO ulid_agg_test VALUES (1, %s, %s)",
+            [(v, v) for v in (mid, hi, lo)],
+        )
+        cur.execute("INSERT INTO ulid_agg_test VALUES (1, NULL, NULL)")
+    yield lo, mid, hi
+    with db.cursor() as cur:
+        cur.execute("DROP TABLE IF EXISTS ulid_agg_test")
+
+
+def test_min_max_agg_under_c_collation(db, mixed_case_table):
+    """Aggregates return the binary min/max where text max(...) under C would not."""
+    lo, mid, hi = mixed_case_table
+    row = exec_fetchone(db, "SELECT ulid_min_agg(c_id), ulid_max_agg(c_id), max(c_id) FROM ulid_agg_test")
+    assert row[0] == lo
+    assert row[1] == hi
+    assert row[2] == mid, "Lowercase input should sort last in C collation text order"
+
+
+def test_min_max_agg_under_non_c_collation(db, mixed_case_table):
+    """Aggregates return the binary min/max under a non-C collation too."""
+    coll = non_c_collation(db)
+    if coll is None:
+        pytest.skip("No non-C collation available in database")
+    lo, mid, hi = mixed_case_table
+    row = exec_fetchone(
+        db,
+        f'SELECT ulid_min_agg(id COLLATE "{coll}"), ulid_max_agg(id COLLATE "{coll}") FROM ulid_agg_test GROUP BY grp',
+    )
+    assert row == (lo, hi)
+
+
+def test_min_max_agg_empty_input(db):
+    """Aggregates over no rows return NULL."""
+    row = exec_fetchone(db, "SELECT ulid_min_agg(x), ulid_max_agg(x) FROM (SELECT NULL::text AS x WHERE false) s")
+    assert row == (None, None)
+
+
+def test_ulid_text_cmp_orders_empty_string_first(db):
+    """ulid_text_cmp() is a total order with '' below every valid ULID."""
+    lo = known_ulid(db, KNOWN_TS_MS)
+    hi = known_ulid(db, KNOWN_TS_MS + 1)
+    assert exec_one(db, "SELECT ulid_text_cmp('', %s)", (lo,)) == -1
+    assert exec_one(db, "SELECT ulid_text_cmp(%s, '')", (lo,)) == 1
+    assert exec_one(db, "SELECT ulid_text_cmp('', '')") == 0
+    assert exec_one(db, "SELECT ulid_text_cmp(%s, %s)", (lo, hi)) == -1
+    assert exec_one(db, "SELECT ulid_text_cmp(%s, lower(%s))", (hi, hi)) == 0
+
+
+def test_ulid_text_cmp_orders_null_last(db):
+    """NULL compares above every value, including ''."""
+    u = known_ulid(db, KNOWN_TS_MS)
+    assert exec_one(db, "SELECT ulid_text_cmp(NULL, %s)", (u,)) == 1
+    assert exec_one(db, "SELECT ulid_text_cmp(%s, NULL)", (u,)) == -1
+    assert exec_one(db, "SELECT ulid_text_cmp(NULL, '')") == 1
+    assert exec_one(db, "SELECT ulid_text_cmp(NULL, NULL)") == 0
+
+
+def test_sorting_list_with_empty_string(db):
+    """ORDER BY ulid_text_sort_key places '' first, ULIDs in binary order, NULL last."""
+    values = [known_ulid(db, KNOWN_TS_MS + 2), None, "", known_ulid(db, KNOWN_TS_MS).lower(), known_ulid(db, KNOWN_TS_MS + 1)]
+    with db.cursor() as cur:
+        cur.execute("SELECT v FROM unnest(%s::text[]) AS v ORDER BY ulid_text_sort_key(v)", (values,))
+        ordered = [r[0] for r in cur.fetchall()]
+    assert ordered == ["", values[3], values[4], values[0], None]
+
+
+def test_ulid_text_sort_key_agrees_with_ulid_text_cmp(db):
+    """Comparing sort keys gives the same sign as ulid_text_cmp."""
+    values = ["", known_ulid(db, KNOWN_TS_MS), known_ulid(db, KNOWN_TS_MS + 1).lower(), known_ulid(db, KNOWN_TS_MS + 1)]
+    for a in values:
+        for b in values:
+            assert exec_one(
+                db,
+                "SELECT sign(ulid_text_cmp(%s, %s)) = "
+                "(CASE WHEN ulid_text_sort_key(%s) < ulid_text_sort_key(%s) THEN -1 "
+                "WHEN ulid_text_sort_key(%s) > ulid_text_sort_key(%s) THEN 1 ELSE 0 END)",
+                (a, b, a, b, a, b),
+            ) is True
+
+
+def test_ulid_text_sort_key_index(db):
+    """An expression index on ulid_text_sort_key serves ORDER BY on ULID text."""
+    with db.cursor() as cur:
+        cur.execute("CREATE TEMP TABLE sort_key_items (id text)")
+        try:
+            cur.execute("INSERT INTO sort_key_items SELECT ulid_random()::text FROM generate_series(1, 50)")
+            cur.execute("CREATE INDEX ON sort_key_items (ulid_text_sort_key(id))")
+            cur.execute("SET enable_seqscan = off")
+            cur.execute("EXPLAIN SELECT id FROM sort_key_items ORDER BY ulid_text_sort_key(id)")
+            plan = "\n".join(r[0] for r in cur.fetchall())
+            assert "Index" in plan
+        finally:
+            cur.execute("RESET enable_seqscan")
+            cur.execute("DROP TABLE sort_key_items")
+
+
+def test_empty_string_in_aggregates_and_between(db):
+    """The text comparison entry points share the same treatment of ''."""
+    u = known_ulid(db, KNOWN_TS_MS)
+    row = exec_fetchone(db, "SELECT ulid_min_agg(v), ulid_max_agg(v) FROM unnest(ARRAY['', %s]) AS v", (u,))
+    assert row == ("", u)
+    assert exec_one(db, "SELECT ulid_between('', %s, '')", (u,)) is True
+
+
+def test_timestamp_histogram_buckets(db):
+    """ULIDs are counted per floored bucket; NULL and invalid entries are skipped."""
+    values = [known_ulid(db, KNOWN_TS_MS + off) for off in (0, 10, 999, 1000, 2500)]
+    values += [None, "not-a-ulid"]
+    with db.cursor() as cur:
+        cur.execute(
+            """
+            SELECT (extract(epoch FROM bucket_start) * 1000)::bigint, count
+            FROM ulid_timestamp_histogram(%s::text[], 1000)
+            """,
+            (values,),
+        )
+        rows = cur.fetchall()
+    assert rows == [(KNOWN_TS_MS, 3), (KNOWN_TS_MS + 1000, 1), (KNOWN_TS_MS + 2000, 1)]
+
+
+def test_timestamp_histogram_rejects_bad_bucket(db):
+    """bucket_ms must be positive."""
+    with pytest.raises(psycopg2.DataError):
+        exec_one(db, "SELECT * FROM ulid_timestamp_histogram(ARRAY[ulid()::text], 0)")
+
+
+SAMPLE_BYTES = list(bytes.fromhex(SAMPLE_HEX))
+
+
+def test_ulid_to_array_known_value(db):
+    """A known ULID decodes to its 16 bytes."""
+    assert exec_one(db, "SELECT ulid_to_array(%s)", (SAMPLE_ULID,)) == SAMPLE_BYTES
+
+
+def test_ulid_array_round_trip(db):
+    """to_array and from_array invert each other."""
+    assert exec_one(db, "SELECT ulid_from_array(%s::int[])", (SAMPLE_BYTES,)) == SAMPLE_ULID
+    ok = exec_one(db, """
+        SELECT bool_and(ulid_from_array(ulid_to_array(u)) = u)
+        FROM (SELECT ulid_random()::text AS u FROM generate_series(1, 50)) s
+    """)
+    assert ok is True
+
+
+@pytest.mark.parametrize("arr", [
+    "ARRAY[]::int[]",
+    "ARRAY[1,2,3]",
+    "array_fill(0, ARRAY[17])",
+    "array_fill(0, ARRAY[4, 4])",
+    "array_fill(0, ARRAY[15]) || 256",
+    "array_fill(0, ARRAY[15]) || -1",
+    "array_fill(0, ARRAY[15]) || NULL::int",
+])
+def test_ulid_from_array_rejects_bad_input(db, arr):
+    """Wrong length, wrong shape, out-of-range and NULL elements are rejected."""
+    with pytest.raises(psycopg2.errors.InvalidParameterValue):
+        exec_one(db, f"SELECT ulid_from_array({arr})")
+
+
+def test_ulid_to_timestamptz_array_mixed(db):
+    """Timestamps line up element-wise and NULL elements pass through."""
+    a, b = known_ulid(db, KNOWN_TS_MS), known_ulid(db, KNOWN_TS_MS + 123)
+    got = exec_one(db, """
+        SELECT array_agg(ts IS NOT DISTINCT FROM expected ORDER BY i)
+        FROM unnest(ulid_to_timestamptz_array(ARRAY[%s, NULL, %s]),
+                    ARRAY[to_timestamp(1640995200), NULL, to_timestamp(1640995200.123)])
+             WITH ORDINALITY AS t(ts, expected, i)
+    """, (a, b))
+    assert got == [True, True, True]
+
+
+def test_ulid_to_timestamptz_array_invalid(db):
+    """Invalid elements raise by default and become NULL when strict is off."""
+    a = known_ulid(db, KNOWN_TS_MS)
+    with pytest.raises(psycopg2.errors.InvalidTextRepresentation):
+        exec_one(db, "SELECT ulid_to_timestamptz_array(ARRAY[%s, 'bogus'])", (a,))
+    got = exec_one(db, "SELECT ulid_to_timestamptz_array(ARRAY[%s, 'bogus'], false)", (a,))
+    assert len(got) == 2 and got[0] is not None and got[1] is None
+    assert exec_one(db, "SELECT cardinality(ulid_to_timestamptz_array('{}'::text[]))") == 0
+
+
+def test_compare_text_vs_binary_agrees_for_canonical(db):
+    """Canonical uppercase ULIDs order the same as text and as binary."""
+    a, b = known_ulid(db, KNOWN_TS_MS), known_ulid(db, KNOWN_TS_MS + 1)
+    assert exec_one(db, 'SELECT ulid_compare_text_vs_binary(%s COLLATE "C", %s)', (a, b)) is True
+    assert exec_one(db, 'SELECT ulid_compare_text_vs_binary(%s COLLATE "C", %s)', (b, a)) is True
+    assert exec_one(db, 'SELECT ulid_compare_text_vs_binary(%s, %s)', (a, a)) is True
+
+
+def test_compare_text_vs_binary_flags_mixed_case(db):
+    """Under C collation a lowercase ULID sorts after a later uppercase one."""
+    a, b = known_ulid(db, KNOWN_TS_MS).lower(), known_ulid(db, KNOWN_TS_MS + 1000)
+    assert exec_one(db, 'SELECT ulid_compare_text_vs_binary(%s COLLATE "C", %s)', (a, b)) is False
+    assert exec_one(db, 'SELECT ulid_compare_text_vs_binary(%s COLLATE "C", upper(%s))', (b, b)) is True
+    assert exec_one(db, 'SELECT ulid_compare_text_vs_binary(%s COLLATE "C", lower(%s))', (b, b)) is False
+
+
+def test_compare_text_vs_binary_non_c_collation(db):
+    """Uppercase ULIDs still agree under a linguistic collation."""
+    coll = non_c_collation(db)
+    if coll is None:
+        pytest.skip("no non-C collation available")
+    pairs = exec_one(db, """
+        SELECT array_agg(u ORDER BY u COLLATE "C")
+        FROM (SELECT ulid_random()::text AS u FROM generate_series(1, 50)) s
+    """)
+    pairs = list(zip(pairs, pairs[1:])) + [(known_ulid(db, KNOWN_TS_MS), known_ulid(db, KNOWN_TS_MS + 1))]
+    for a, b in pairs:
+        ok = exec_one(db, f'SELECT ulid_compare_text_vs_binary(%s COLLATE "{coll}", %s)', (a, b))
+        assert ok is True, f"{a} vs {b} disagree under {coll}"
+
+
+def test_entropy_score_random_vs_biased(db):
+    """Random entropy scores near the 255 degrees of freedom; constant entropy scores far higher."""
+    random_score = exec_one(db, """
+        SELECT ulid_entropy_score(array_agg(ulid_random()::text))
+        FROM generate_series(1, 1000)
+    """)
+    biased_score = exec_one(db, """
+        SELECT ulid_entropy_score(array_agg(ulid_set_entropy(ulid_random()::text, 'ffffffffffffffffffff')))
+        FROM generate_series(1, 100)
+    """)
+    assert 150 < random_score < 400
+    assert biased_score == 255000
+
+
+def test_entropy_score_skips_invalid_and_empty(db):
+    """NULL and invalid entries are ignored; nothing left scores NULL."""
+    assert exec_one(db, "SELECT ulid_entropy_score(ARRAY[NULL, 'bogus']::text[])") is None
+    assert exec_one(db, "SELECT ulid_entropy_score('{}'::text[])") is None
+
+
+def test_clock_skew_report_bounds(db):
+    """Past and future ULIDs bound the reported skew; now() is fixed within the transaction."""
+    now_ms = exec_one(db, "SELECT floor(extract(epoch FROM now()) * 1000)::bigint")
+    ids = [known_ulid(db, now_ms + d) for d in (-60000, 0, 5000)]
+    with db.cursor() as cur:
+        cur.execute("BEGIN")
+        try:
+            cur.execute("SELECT floor(extract(epoch FROM now()) * 1000)::bigint")
+            tx_now = cur.fetchone()[0]
+            cur.execute("SELECT * FROM ulid_clock_skew_report(%s || ARRAY[NULL, 'bogus'])", (ids,))
+            lo, hi, avg = cur.fetchone()
+        finally:
+            cur.execute("ROLLBACK")
+    offset = now_ms - tx_now
+    assert lo == -60000 + offset
+    assert hi == 5000 + offset
+    assert float(avg) == pytest.approx((-60000 + 0 + 5000) / 3 + offset)
+
+
+def test_clock_skew_report_empty(db):
+    """Nothing valid to report yields all NULLs."""
+    assert exec_fetchone(db, "SELECT * FROM ulid_clock_skew_report(ARRAY['bogus'])") == (None, None, None)
+
+
+def test_dedupe_array_collapses_mixed_case(db):
+    """Case variants of one ULID collapse to the first spelling, in first-seen order."""
+    a, b, c = known_ulid(db, KNOWN_TS_MS + 2), known_ulid(db, KNOWN_TS_MS), known_ulid(db, KNOWN_TS_MS + 1)
+    got = exec_one(db, "SELECT ulid_dedupe_array(%s)", ([a, b.lower(), a.lower(), c, b, a],))
+    assert got == [a, b.lower(), c]
+
+
+def test_dedupe_array_keeps_one_empty_string(db):
+    """'' is accepted as ulid_text_cmp treats it, and its duplicates collapse."""
+    a = known_ulid(db, KNOWN_TS_MS)
+    assert exec_one(db, "SELECT ulid_dedupe_array(%s)", ([a, "", a.lower(), ""],)) == [a, ""]
+
+
+def test_dedupe_array_edge_cases(db):
+    """Empty arrays stay empty and invalid entries raise."""
+    assert exec_one(db, "SELECT ulid_dedupe_array('{}'::text[])") == []
+    with pytest.raises(psycopg2.errors.InvalidTextRepresentation):
+        exec_one(db, "SELECT ulid_dedupe_array(ARRAY['bogus'])")
+
+
+def test_sort_array_binary_order(db):
+    """Sorting follows binary value in both directions and keeps ties in input order."""
+    lo, mid, hi = (known_ulid(db, KNOWN_TS_MS + d) for d in (0, 1000, 2000))
+    arr = [mid.lower(), hi, None, lo, mid]
+    assert exec_one(db, "SELECT ulid_sort_array(%s)", (arr,)) == [lo, mid.lower(), mid, hi, None]
+    assert exec_one(db, "SELECT ulid_sort_array(%s, true)", (arr,)) == [hi, mid.lower(), mid, lo, None]
+
+
+def test_sort_array_places_empty_string_as_ulid_text_cmp_does(db):
+    """'' sorts first ascending and last (before NULLs) descending."""
+    lo, hi = known_ulid(db, KNOWN_TS_MS), known_ulid(db, KNOWN_TS_MS + 1)
+    arr = [hi, None, "", lo]
+    assert exec_one(db, "SELECT ulid_sort_array(%s)", (arr,)) == ["", lo, hi, None]
+    assert exec_one(db, "SELECT ulid_sort_array(%s, true)", (arr,)) == [hi, lo, "", None]
+
+
+def test_sort_array_under_non_c_collation(db):
+    """The result is the same whatever the collation of the input."""
+    coll = non_c_collation(db)
+    if coll is None:
+        pytest.skip("no non-C collation available")
+    ok = exec_one(db, f"""
+        WITH s AS (SELECT array_agg(ulid_random()::text) AS arr FROM generate_series(1, 200))
+        SELECT ulid_sort_array(arr) = (SELECT array_agg(u ORDER BY u::ulid) FROM unnest(arr) AS u)
+           AND ulid_sort_array(arr::text[] COLLATE "{coll}") = ulid_sort_array(arr COLLATE "C")
+        FROM s
+    """)
+    assert ok is True
+
+
+def test_ulid_to_numeric_known_value(db):
+    """The numeric value is the big-endian integer of the 16 bytes."""
+    got = exec_one(db, "SELECT ulid_to_numeric(%s)", (SAMPLE_ULID,))
+    assert int(got) == int.from_bytes(bytes(SAMPLE_BYTES), "big")
+    assert exec_one(db, "SELECT ulid_to_numeric('00000000000000000000000000')") == 0
+    assert int(exec_one(db, "SELECT ulid_to_numeric(ulid_max_value())")) == 2 ** 126 - 1
+
+
+def test_ulid_to_numeric_preserves_order(db):
+    """Numeric order matches ULID order."""
+    ok = exec_one(db, """
+        SELECT bool_and(ulid_to_numeric(a) < ulid_to_numeric(b))
+        FROM (SELECT u AS a, lead(u) OVER (ORDER BY u::ulid) AS b
+              FROM (SELECT ulid_random()::text AS u FROM generate_series(1, 100)) s) p
+        WHERE b IS NOT NULL
+    """)
+    assert ok is True
+
+
+def test_nearest_exact_and_closest(db):
+    """An exact timestamp match wins; otherwise the smallest distance in either direction."""
+    a, b, c = (known_ulid(db, KNOWN_TS_MS + d) for d in (0, 1000, 5000))
+    assert exec_one(db, "SELECT ulid_nearest(%s, %s)", (KNOWN_TS_MS + 1000, [a, c, b])) == b
+    assert exec_one(db, "SELECT ulid_nearest(%s, %s)", (KNOWN_TS_MS + 3500, [a, b, c])) == c
+    assert exec_one(db, "SELECT ulid_nearest(%s, %s)", (0, [c, None, b, a])) == a
+
+
+def test_nearest_ties_break_by_binary_order(db):
+    """Equidistant candidates resolve to the smaller ULID regardless of array order."""
+    before = with_entropy(db, KNOWN_TS_MS - 10, "ffffffffffffffffffff")
+    later_big = with_entropy(db, KNOWN_TS_MS + 10, "00000000000000000001")
+    later_small = with_entropy(db, KNOWN_TS_MS + 10, "00000000000000000000")
+    assert exec_one(db, "SELECT ulid_nearest(%s, %s)", (KNOWN_TS_MS, [later_big, before])) == before
+    assert exec_one(db, "SELECT ulid_nearest(%s, %s)", (KNOWN_TS_MS + 10, [later_big, later_small])) == later_small
+    assert exec_one(db, "SELECT ulid_nearest(%s, ARRAY[NULL]::text[])", (KNOWN_TS_MS,)) is None